@@ -1,34 +1,65 @@
 package quickwit
 
 import (
-	"fmt"
+	"context"
+	"log/slog"
 	"net/http"
-
-	"github.com/sirupsen/logrus"
+	"sync"
 )
 
 type client struct {
-	log          logrus.FieldLogger
+	log          Logger
 	endpoint     string
-	interceptors []reqModifier
+	interceptors []Interceptor
+	middlewares  []Middleware
+	retry        *RetryPolicy
+	breaker      *CircuitBreaker
+	limiter      *RateLimiter
+	pool         *NodePool
+	hedger       *Hedger
+	signer       *SigV4Signer
+	tls          *TLSConfig
+	telemetryCfg *TelemetryConfig
+	telemetry    *telemetry
 	httpClient   *http.Client
+	customDoer   Doer
+
+	// transport actually used by the API methods, see buildDoer
+	doer Doer
+
+	// cluster capabilities, fetched on first use
+	capsMu sync.Mutex
+	caps   *Capabilities
 }
 
 type clientOption func(*client)
-type reqModifier func(req *http.Request)
 
 func New(opts ...clientOption) Client {
 	c := client{
 		endpoint:     DefaultEndpoint,
-		interceptors: []reqModifier{},
+		interceptors: []Interceptor{},
 		httpClient:   http.DefaultClient,
-		log:          logrus.New(),
+		log:          NewSlogLogger(slog.Default()),
 	}
 
 	for _, opt := range opts {
 		opt(&c)
 	}
 
+	if c.telemetryCfg != nil {
+		t, err := newTelemetry(*c.telemetryCfg)
+		if err != nil {
+			c.log.Error("cannot set up telemetry, API calls will not be instrumented", "error", err)
+		}
+		c.telemetry = t
+	}
+
+	c.doer = c.buildDoer()
+
+	if c.pool != nil {
+		c.pool.startDiscovery(&c, c.log)
+	}
+
 	return &c
 }
 
@@ -44,24 +75,110 @@ func WithBearerToken(token string) func(*client) {
 			return
 		}
 
-		c.interceptors = append(c.interceptors, func(req *http.Request) {
-			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-		})
+		c.interceptors = append(c.interceptors, tokenInterceptor(StaticTokenSource(token)))
+	}
+}
+
+// Authenticate with bearer tokens provided by the source, see StaticTokenSource,
+// NewFileTokenSource and NewClientCredentialsTokenSource.
+// A 401 response invalidates the token and the request is sent once more with a fresh one.
+func WithTokenSource(ts TokenSource) func(*client) {
+	return func(c *client) {
+		if ts == nil {
+			return
+		}
+
+		c.interceptors = append(c.interceptors, tokenInterceptor(ts))
 	}
 }
 
-func WithLogger(logger logrus.FieldLogger) func(*client) {
-	return func(c *client) { c.log = logger }
+// Set the client logger, see NewSlogLogger, NewNopLogger and qwlogrus.NewLogger
+func WithLogger(logger Logger) func(*client) {
+	return func(c *client) {
+		if logger == nil {
+			logger = NewNopLogger()
+		}
+		c.log = logger
+	}
 }
 
 func WithBasicAuth(user, password string) func(*client) {
 	return func(c *client) {
-		c.interceptors = append(c.interceptors, func(req *http.Request) {
+		c.interceptors = append(c.interceptors, func(ctx context.Context, req *http.Request, next Next) (*http.Response, error) {
 			req.SetBasicAuth(user, password)
+			return next(ctx, req)
 		})
 	}
 }
 
+// Send requests with the given client, its timeout, proxy and TLS settings apply to every API call
 func WithHttpClient(httpClient *http.Client) func(*client) {
-	return func(c *client) { c.httpClient = httpClient }
+	return func(c *client) {
+		if httpClient != nil {
+			c.httpClient = httpClient
+		}
+	}
+}
+
+// Configure TLS (CA bundle, mTLS client certificate, server name, minimum version) on the HTTP client transport
+func WithTLS(cfg TLSConfig) func(*client) {
+	return func(c *client) { c.tls = &cfg }
+}
+
+// Send requests through a custom Doer instead of an *http.Client, middlewares still apply
+func WithDoer(doer Doer) func(*client) {
+	return func(c *client) { c.customDoer = doer }
+}
+
+// Retry failed API calls, see RetryMiddleware for the requests eligible to retries
+func WithRetry(policy RetryPolicy) func(*client) {
+	return func(c *client) { c.retry = &policy }
+}
+
+// Fail fast while an endpoint keeps failing, the breaker can be shared between clients
+func WithCircuitBreaker(breaker *CircuitBreaker) func(*client) {
+	return func(c *client) { c.breaker = breaker }
+}
+
+// Throttle requests client-side, the limiter can be shared between clients
+func WithRateLimiter(limiter *RateLimiter) func(*client) {
+	return func(c *client) { c.limiter = limiter }
+}
+
+// Spread requests over the nodes of a cluster with failover, the pool can be shared between clients.
+// Discovery runs in the background until the pool is closed.
+func WithNodePool(pool *NodePool) func(*client) {
+	return func(c *client) { c.pool = pool }
+}
+
+// Send duplicates of slow read-only requests to other nodes and keep the first answer, see HedgingPolicy
+func WithHedging(hedger *Hedger) func(*client) {
+	return func(c *client) { c.hedger = hedger }
+}
+
+// Sign every request with AWS Signature Version 4, for deployments behind API Gateway or an ALB
+func WithSigV4(region, service string, credentials CredentialsProvider) func(*client) {
+	return WithSigV4Signer(NewSigV4Signer(region, service, credentials))
+}
+
+// Sign every request with a custom SigV4 signer, see SigV4Signer.UnsignedStreamingPayload
+func WithSigV4Signer(signer *SigV4Signer) func(*client) {
+	return func(c *client) { c.signer = signer }
+}
+
+// Create a span per API call and record request metrics with OpenTelemetry,
+// the trace context is propagated to Quickwit through the request headers
+func WithTelemetry(cfg TelemetryConfig) func(*client) {
+	return func(c *client) { c.telemetryCfg = &cfg }
+}
+
+// Add interceptors around every request, the first one being the outermost
+func WithInterceptor(interceptors ...Interceptor) func(*client) {
+	return func(c *client) { c.interceptors = append(c.interceptors, interceptors...) }
+}
+
+// Wrap the transport with middlewares, the first one being the outermost.
+// See RetryMiddleware, MetricsMiddleware and LoggingMiddleware.
+func WithMiddleware(middlewares ...Middleware) func(*client) {
+	return func(c *client) { c.middlewares = append(c.middlewares, middlewares...) }
 }
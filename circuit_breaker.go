@@ -0,0 +1,268 @@
+package quickwit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type CircuitState int
+
+const (
+	// CircuitClosed lets requests through, failures are counted
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects requests until the cool-down is over
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of probe requests through to decide whether to close the circuit
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+var ErrCircuitOpen = errors.New("quickwit: circuit breaker is open")
+
+// CircuitOpenError is returned without sending the request while the circuit of an endpoint is open.
+// It matches ErrCircuitOpen with errors.Is.
+type CircuitOpenError struct {
+	Endpoint string
+	State    CircuitState
+	// Remaining cool-down, zero while half-open
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("quickwit: circuit breaker is %s for %s, retry after %s", e.State, e.Endpoint, e.RetryAfter)
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+const (
+	DefaultCircuitFailureThreshold = 5
+	DefaultCircuitCoolDown         = 30 * time.Second
+)
+
+type CircuitBreakerConfig struct {
+	// Consecutive failures opening the circuit
+	FailureThreshold int
+	// Time spent open before letting probe requests through
+	CoolDown time.Duration
+	// Concurrent probe requests allowed while half-open
+	HalfOpenMaxRequests int
+	// Successful probes needed to close the circuit
+	SuccessThreshold int
+	// Decides whether a request failed, defaults to network errors and 5xx responses
+	IsFailure func(res *http.Response, err error) bool
+	// Called on every state transition, outside of the breaker lock
+	OnStateChange func(endpoint string, from, to CircuitState)
+}
+
+func (cfg CircuitBreakerConfig) withDefaults() CircuitBreakerConfig {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = DefaultCircuitFailureThreshold
+	}
+	if cfg.CoolDown <= 0 {
+		cfg.CoolDown = DefaultCircuitCoolDown
+	}
+	if cfg.HalfOpenMaxRequests <= 0 {
+		cfg.HalfOpenMaxRequests = 1
+	}
+	if cfg.SuccessThreshold <= 0 {
+		cfg.SuccessThreshold = 1
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = isCircuitFailure
+	}
+	return cfg
+}
+
+func isCircuitFailure(res *http.Response, err error) bool {
+	return err != nil || res.StatusCode >= 500
+}
+
+// CircuitBreaker tracks one circuit per endpoint (host:port)
+type CircuitBreaker struct {
+	cfg CircuitBreakerConfig
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+
+	now func() time.Time
+}
+
+type circuit struct {
+	state     CircuitState
+	failures  int
+	successes int
+	// Probes in flight, only requests admitted while half-open are probes
+	probes   int
+	openedAt time.Time
+	// Incremented every time the circuit becomes half-open, so that late probes of a previous half-open period are ignored
+	halfOpens uint64
+}
+
+func NewCircuitBreaker(cfg CircuitBreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{
+		cfg:      cfg.withDefaults(),
+		circuits: map[string]*circuit{},
+		now:      time.Now,
+	}
+}
+
+// State returns the current state of the endpoint circuit
+func (cb *CircuitBreaker) State(endpoint string) CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c, ok := cb.circuits[endpoint]
+	if !ok {
+		return CircuitClosed
+	}
+	if c.state == CircuitOpen && cb.now().Sub(c.openedAt) >= cb.cfg.CoolDown {
+		return CircuitHalfOpen
+	}
+	return c.state
+}
+
+// Middleware rejects requests to endpoints whose circuit is open and records the outcome of the others.
+// Health probes (livez, readyz) bypass the breaker: they must reach starting nodes, which answer them with 503.
+// So do flamegraph polls, answered with 500 until the CPU profiling is over.
+func (cb *CircuitBreaker) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			switch op, _ := OperationFromContext(req.Context()); op.Name {
+			case OpLivez, OpReadyz, OpFlamegraph:
+				return next.RoundTrip(req)
+			}
+
+			endpoint := req.URL.Host
+
+			probe, err := cb.allow(endpoint)
+			if err != nil {
+				return nil, err
+			}
+
+			res, err := next.RoundTrip(req)
+
+			// a cancelled call, or an interceptor failure, says nothing about the endpoint health
+			if req.Context().Err() != nil || isInterceptorError(err) {
+				cb.release(endpoint, probe)
+				return res, err
+			}
+
+			cb.record(endpoint, probe, !cb.cfg.IsFailure(res, err))
+
+			return res, err
+		})
+	}
+}
+
+// allow returns the half-open period of the request when it is a probe, zero otherwise
+func (cb *CircuitBreaker) allow(endpoint string) (uint64, error) {
+	cb.mu.Lock()
+
+	c, ok := cb.circuits[endpoint]
+	if !ok {
+		c = &circuit{}
+		cb.circuits[endpoint] = c
+	}
+
+	from := c.state
+	if c.state == CircuitOpen {
+		if remaining := cb.cfg.CoolDown - cb.now().Sub(c.openedAt); remaining > 0 {
+			cb.mu.Unlock()
+			return 0, &CircuitOpenError{Endpoint: endpoint, State: CircuitOpen, RetryAfter: remaining}
+		}
+		c.state = CircuitHalfOpen
+		c.successes = 0
+		c.probes = 0
+		c.halfOpens++
+	}
+
+	var probe uint64
+	var err error
+	if c.state == CircuitHalfOpen {
+		if c.probes >= cb.cfg.HalfOpenMaxRequests {
+			err = &CircuitOpenError{Endpoint: endpoint, State: CircuitHalfOpen}
+		} else {
+			c.probes++
+			probe = c.halfOpens
+		}
+	}
+
+	to := c.state
+	cb.mu.Unlock()
+
+	cb.notify(endpoint, from, to)
+	return probe, err
+}
+
+func (cb *CircuitBreaker) release(endpoint string, probe uint64) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if c, ok := cb.circuits[endpoint]; ok && probe != 0 && probe == c.halfOpens && c.state == CircuitHalfOpen {
+		c.probes--
+	}
+}
+
+func (cb *CircuitBreaker) record(endpoint string, probe uint64, success bool) {
+	cb.mu.Lock()
+
+	c := cb.circuits[endpoint]
+	from := c.state
+
+	switch {
+	case probe != 0:
+		// probes of a previous half-open period, or finishing after another probe failed, are ignored
+		if probe != c.halfOpens || c.state != CircuitHalfOpen {
+			break
+		}
+		c.probes--
+		if !success {
+			c.state = CircuitOpen
+			c.openedAt = cb.now()
+			break
+		}
+		c.successes++
+		if c.successes >= cb.cfg.SuccessThreshold {
+			c.state = CircuitClosed
+			c.failures = 0
+		}
+	case c.state != CircuitClosed:
+		// requests admitted before the circuit opened say nothing about the recovery
+	case success:
+		c.failures = 0
+	default:
+		c.failures++
+		if c.failures >= cb.cfg.FailureThreshold {
+			c.state = CircuitOpen
+			c.openedAt = cb.now()
+		}
+	}
+
+	to := c.state
+	cb.mu.Unlock()
+
+	cb.notify(endpoint, from, to)
+}
+
+func (cb *CircuitBreaker) notify(endpoint string, from, to CircuitState) {
+	if from != to && cb.cfg.OnStateChange != nil {
+		cb.cfg.OnStateChange(endpoint, from, to)
+	}
+}
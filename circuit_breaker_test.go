@@ -0,0 +1,96 @@
+package quickwit
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var transitions []CircuitState
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{
+		FailureThreshold: 2,
+		CoolDown:         30 * time.Second,
+		OnStateChange: func(endpoint string, from, to CircuitState) {
+			transitions = append(transitions, to)
+		},
+	})
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	breaker.now = func() time.Time { return now }
+
+	status := http.StatusServiceUnavailable
+	calls := 0
+	rt := breaker.Middleware()(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+	}))
+
+	req, err := http.NewRequest(http.MethodGet, "http://quickwit:7280/api/v1/indexes", nil)
+	require.NoError(t, err)
+
+	for range 2 {
+		_, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, CircuitOpen, breaker.State("quickwit:7280"))
+
+	now = now.Add(10 * time.Second)
+	_, err = rt.RoundTrip(req)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	var openErr *CircuitOpenError
+	require.ErrorAs(t, err, &openErr)
+	assert.Equal(t, 20*time.Second, openErr.RetryAfter)
+	assert.Equal(t, 2, calls, "open circuit must not send requests")
+
+	now = now.Add(20 * time.Second)
+	assert.Equal(t, CircuitHalfOpen, breaker.State("quickwit:7280"))
+	status = http.StatusOK
+
+	_, err = rt.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, CircuitClosed, breaker.State("quickwit:7280"))
+	assert.Equal(t, []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitClosed}, transitions)
+}
+
+func TestCircuitBreakerProbes(t *testing.T) {
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, CoolDown: time.Second})
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	breaker.now = func() time.Time { return now }
+
+	const endpoint = "quickwit:7280"
+
+	// admitted while closed, still in flight when the circuit opens
+	slow, err := breaker.allow(endpoint)
+	require.NoError(t, err)
+	assert.Zero(t, slow)
+
+	failed, err := breaker.allow(endpoint)
+	require.NoError(t, err)
+	breaker.record(endpoint, failed, false)
+	assert.Equal(t, CircuitOpen, breaker.State(endpoint))
+
+	now = now.Add(time.Second)
+	probe, err := breaker.allow(endpoint)
+	require.NoError(t, err, "requests in flight before the circuit opened are not probes")
+	assert.NotZero(t, probe)
+
+	_, err = breaker.allow(endpoint)
+	assert.ErrorIs(t, err, ErrCircuitOpen, "a single probe is allowed while half-open")
+
+	// the slow request succeeds, the circuit waits for the probe
+	breaker.record(endpoint, slow, true)
+	assert.Equal(t, CircuitHalfOpen, breaker.State(endpoint))
+
+	breaker.record(endpoint, probe, false)
+	assert.Equal(t, CircuitOpen, breaker.State(endpoint))
+
+	now = now.Add(time.Second)
+	probe, err = breaker.allow(endpoint)
+	require.NoError(t, err)
+	breaker.record(endpoint, probe, true)
+	assert.Equal(t, CircuitClosed, breaker.State(endpoint))
+}
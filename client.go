@@ -3,14 +3,14 @@ package quickwit
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
 	"time"
-
-	"github.com/sirupsen/logrus"
 )
 
 type Client interface {
@@ -19,47 +19,54 @@ type Client interface {
 	ListIndexes(ctx context.Context) ([]Index, error)
 	GetIndex(ctx context.Context, indexID string) (*Index, error)
 	CreateIndex(ctx context.Context, idx IndexConfig) (*Index, error)
+	UpdateIndex(ctx context.Context, indexID string, cfg IndexConfig) (*Index, error)
 	DeleteIndex(ctx context.Context, indexID string) error
 	ClearIndex(ctx context.Context, indexID string) error
 	DescribeIndex(ctx context.Context, indexID string) (*Describe, error)
 	ListSplits(ctx context.Context, indexID string) (*SplitsRes, error)
+	Ingest(ctx context.Context, indexID string, docs io.Reader) (*IngestResponse, error)
 
 	CreateSource(ctx context.Context, idx string, src SourceConfig) (*SourceConfig, error)
 	DeleteSource(ctx context.Context, indexID, sourceID string) error
+	Apply(ctx context.Context, desired []IndexSpec, opts ApplyOptions) (*ApplyReport, error)
 
 	GetElastic(ctx context.Context) (*Cluster, error)
 	GetCluster(ctx context.Context) (*Cluster, error)
+	WatchCluster(ctx context.Context, interval time.Duration) <-chan ClusterEvent
+
+	Version(ctx context.Context) (*VersionInfo, error)
+	Capabilities(ctx context.Context) (*Capabilities, error)
+	Metrics(ctx context.Context) (*MetricsSnapshot, error)
+
+	Livez(ctx context.Context) (bool, error)
+	Readyz(ctx context.Context) (bool, error)
+	WaitUntilReady(ctx context.Context, opts WaitOptions) error
+	NodesHealth(ctx context.Context) ([]NodeHealth, error)
+
+	SetLogLevel(ctx context.Context, filter string) error
+	CPUProfile(ctx context.Context, w io.Writer, opts CPUProfileOptions) error
+	HeapProfile(ctx context.Context, w io.Writer) error
+	ProfileNodes(ctx context.Context, dir string, opts ProfileNodesOptions) ([]NodeProfile, error)
 }
 
 func (c *client) Search(ctx context.Context, indexID, query string) (*SearchResponse, error) {
-	var req *http.Request
-	var err error
-
-	req, err = http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/v1/%s/search?query=%s", c.endpoint, indexID, query), nil)
+	req, err := c.newRequest(ctx, Operation{Name: OpSearch, IndexID: indexID}, http.MethodGet, fmt.Sprintf("/api/v1/%s/search?query=%s", indexID, query), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, interceptor := range c.interceptors {
-		interceptor(req)
-	}
-
-	return Request[SearchResponse](c.log, req)
+	return Request[SearchResponse](c.doer, c.log, req)
 }
 
 // func (c *client) StreamSearchIndex(ctx context.Context, indexID string) error {}
 
 func (c *client) ListIndexes(ctx context.Context) ([]Index, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/v1/indexes", c.endpoint), nil)
+	req, err := c.newRequest(ctx, Operation{Name: OpListIndexes}, "GET", "/api/v1/indexes", nil)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, interceptor := range c.interceptors {
-		interceptor(req)
-	}
-
-	indexes, err := GetList[Index](c.log, req)
+	indexes, err := GetList[Index](c.doer, c.log, req)
 	if err != nil {
 		return nil, err
 	}
@@ -68,16 +75,12 @@ func (c *client) ListIndexes(ctx context.Context) ([]Index, error) {
 }
 
 func (c *client) GetIndex(ctx context.Context, indexID string) (*Index, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/v1/indexes/%s", c.endpoint, indexID), nil)
+	req, err := c.newRequest(ctx, Operation{Name: OpGetIndex, IndexID: indexID}, "GET", fmt.Sprintf("/api/v1/indexes/%s", indexID), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, interceptor := range c.interceptors {
-		interceptor(req)
-	}
-
-	index, err := Request[Index](c.log, req)
+	index, err := Request[Index](c.doer, c.log, req)
 	if err != nil {
 		return nil, err
 	}
@@ -85,24 +88,32 @@ func (c *client) GetIndex(ctx context.Context, indexID string) (*Index, error) {
 	return index, nil
 }
 
+// CreateIndex sets the config version matching the cluster when idx.Version is empty,
+// or DefaultIndexConfigVersion when the cluster version cannot be fetched
 func (c *client) CreateIndex(ctx context.Context, idx IndexConfig) (*Index, error) {
+	if idx.Version == "" {
+		idx.Version = DefaultIndexConfigVersion
+		if caps, err := c.Capabilities(ctx); err == nil {
+			idx.Version = caps.IndexConfigVersion
+		} else {
+			c.log.Warn("cannot negotiate the index config version, using the default one", "version", DefaultIndexConfigVersion, "error", err)
+		}
+	}
+
 	body := MustMarshall(idx)
 
-	req, err := http.NewRequestWithContext(
+	req, err := c.newRequest(
 		ctx,
+		Operation{Name: OpCreateIndex, IndexID: idx.ID},
 		"POST",
-		fmt.Sprintf("%s/api/v1/indexes", c.endpoint),
+		"/api/v1/indexes",
 		body,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, interceptor := range c.interceptors {
-		interceptor(req)
-	}
-
-	index, err := Request[Index](c.log, req)
+	index, err := Request[Index](c.doer, c.log, req)
 	if err != nil {
 		return nil, err
 	}
@@ -111,42 +122,30 @@ func (c *client) CreateIndex(ctx context.Context, idx IndexConfig) (*Index, erro
 }
 
 func (c *client) DeleteIndex(ctx context.Context, indexID string) error {
-	req, err := http.NewRequestWithContext(ctx, "DELETE", fmt.Sprintf("%s/api/v1/indexes/%s", c.endpoint, indexID), nil)
+	req, err := c.newRequest(ctx, Operation{Name: OpDeleteIndex, IndexID: indexID}, "DELETE", fmt.Sprintf("/api/v1/indexes/%s", indexID), nil)
 	if err != nil {
 		return err
 	}
 
-	for _, interceptor := range c.interceptors {
-		interceptor(req)
-	}
-
-	return RequestNoContent(c.log, req)
+	return RequestNoContent(c.doer, c.log, req)
 }
 
 func (c *client) ClearIndex(ctx context.Context, indexID string) error {
-	req, err := http.NewRequestWithContext(ctx, "PUT", fmt.Sprintf("%s/api/v1/indexes/%s/clear", c.endpoint, indexID), nil)
+	req, err := c.newRequest(ctx, Operation{Name: OpClearIndex, IndexID: indexID}, "PUT", fmt.Sprintf("/api/v1/indexes/%s/clear", indexID), nil)
 	if err != nil {
 		return err
 	}
 
-	for _, interceptor := range c.interceptors {
-		interceptor(req)
-	}
-
-	return RequestNoContent(c.log, req)
+	return RequestNoContent(c.doer, c.log, req)
 }
 
 func (c *client) DescribeIndex(ctx context.Context, indexID string) (*Describe, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/v1/indexes/%s/describe", c.endpoint, indexID), nil)
+	req, err := c.newRequest(ctx, Operation{Name: OpDescribeIndex, IndexID: indexID}, "GET", fmt.Sprintf("/api/v1/indexes/%s/describe", indexID), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, interceptor := range c.interceptors {
-		interceptor(req)
-	}
-
-	index, err := Request[Describe](c.log, req)
+	index, err := Request[Describe](c.doer, c.log, req)
 	if err != nil {
 		return nil, err
 	}
@@ -155,41 +154,65 @@ func (c *client) DescribeIndex(ctx context.Context, indexID string) (*Describe,
 }
 
 func (c *client) ListSplits(ctx context.Context, indexID string) (*SplitsRes, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/v1/indexes/%s/splits", c.endpoint, indexID), nil)
+	req, err := c.newRequest(ctx, Operation{Name: OpListSplits, IndexID: indexID}, "GET", fmt.Sprintf("/api/v1/indexes/%s/splits", indexID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := Request[SplitsRes](c.doer, c.log, req)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, interceptor := range c.interceptors {
-		interceptor(req)
+	return index, nil
+}
+
+// Ingest sends newline-delimited JSON documents to the index
+func (c *client) Ingest(ctx context.Context, indexID string, docs io.Reader) (*IngestResponse, error) {
+	req, err := c.newRequest(
+		ctx,
+		Operation{Name: OpIngest, IndexID: indexID},
+		"POST",
+		fmt.Sprintf("/api/v1/%s/ingest", indexID),
+		docs,
+	)
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
 
-	index, err := Request[SplitsRes](c.log, req)
+	res, err := Request[IngestResponse](c.doer, c.log, req)
 	if err != nil {
 		return nil, err
 	}
 
-	return index, nil
+	return res, nil
 }
 
 func (c *client) CreateSource(ctx context.Context, idx string, src SourceConfig) (*SourceConfig, error) {
+	if src.Version == "" {
+		src.Version = DefaultIndexConfigVersion
+		if caps, err := c.Capabilities(ctx); err == nil {
+			src.Version = caps.IndexConfigVersion
+		} else {
+			c.log.Warn("cannot negotiate the source config version, using the default one", "version", DefaultIndexConfigVersion, "error", err)
+		}
+	}
+
 	body := MustMarshall(src)
 
-	req, err := http.NewRequestWithContext(
+	req, err := c.newRequest(
 		ctx,
+		Operation{Name: OpCreateSource, IndexID: idx},
 		"POST",
-		fmt.Sprintf("%s/api/v1/indexes/%s/sources", c.endpoint, idx),
+		fmt.Sprintf("/api/v1/indexes/%s/sources", idx),
 		body,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, interceptor := range c.interceptors {
-		interceptor(req)
-	}
-
-	s, err := Request[SourceConfig](c.log, req)
+	s, err := Request[SourceConfig](c.doer, c.log, req)
 	if err != nil {
 		return nil, err
 	}
@@ -210,29 +233,21 @@ func WithRetention(d time.Duration) func(cfg *IndexConfig) {
 }
 
 func (c *client) DeleteSource(ctx context.Context, indexID, sourceId string) error {
-	req, err := http.NewRequestWithContext(ctx, "DELETE", fmt.Sprintf("%s/api/v1/indexes/%s/sources/%s", c.endpoint, indexID, sourceId), nil)
+	req, err := c.newRequest(ctx, Operation{Name: OpDeleteSource, IndexID: indexID}, "DELETE", fmt.Sprintf("/api/v1/indexes/%s/sources/%s", indexID, sourceId), nil)
 	if err != nil {
 		return err
 	}
 
-	for _, interceptor := range c.interceptors {
-		interceptor(req)
-	}
-
-	return RequestNoContent(c.log, req)
+	return RequestNoContent(c.doer, c.log, req)
 }
 
 func (c *client) GetElastic(ctx context.Context) (*Cluster, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/v1/_elastic", c.endpoint), nil)
+	req, err := c.newRequest(ctx, Operation{Name: OpGetElastic}, "GET", "/api/v1/_elastic", nil)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, interceptor := range c.interceptors {
-		interceptor(req)
-	}
-
-	cluster, err := Request[Cluster](c.log, req)
+	cluster, err := Request[Cluster](c.doer, c.log, req)
 	if err != nil {
 		return nil, err
 	}
@@ -241,110 +256,163 @@ func (c *client) GetElastic(ctx context.Context) (*Cluster, error) {
 }
 
 func (c *client) GetCluster(ctx context.Context) (*Cluster, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/v1/cluster?format=pretty_json", c.endpoint), nil)
+	req, err := c.newRequest(ctx, Operation{Name: OpGetCluster}, "GET", "/api/v1/cluster?format=pretty_json", nil)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, interceptor := range c.interceptors {
-		interceptor(req)
+	cluster, err := Request[Cluster](c.doer, c.log, req)
+	if err != nil {
+		return nil, err
 	}
 
-	//logrus.Debugf("REQ: %+v", req)
+	return cluster, nil
+}
 
-	cluster, err := Request[Cluster](c.log, req)
+// newRequest builds a request to the client endpoint, or the node targeted by the context, tagged with the operation it belongs to
+func (c *client) newRequest(ctx context.Context, op Operation, method, path string, body io.Reader) (*http.Request, error) {
+	if op.Class == "" {
+		op.Class = operationClass(op.Name)
+	}
+
+	ctx = withOperation(ctx, op)
+	if c.telemetry != nil {
+		ctx = c.telemetry.start(ctx, op)
+	}
+
+	endpoint := c.endpoint
+	if node, ok := targetNodeFromContext(ctx); ok {
+		endpoint = node
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint+path, body)
 	if err != nil {
+		callFromContext(ctx).end(err)
 		return nil, err
 	}
 
-	return cluster, nil
+	if c.telemetry != nil {
+		c.telemetry.inject(req)
+	}
+
+	return req, nil
 }
 
-func Request[T any](log logrus.FieldLogger, req *http.Request) (*T, error) {
+func Request[T any](doer Doer, log Logger, req *http.Request) (*T, error) {
 	t := new(T)
 
-	res, err := http.DefaultClient.Do(req)
+	err := send(doer, log, req, func(body io.Reader) error {
+		//payload, _ := io.ReadAll(body)
+		//fmt.Printf("%+v\n", string(payload))
+
+		if err := json.NewDecoder(body).Decode(t); err != nil {
+			return err
+		}
+		if h, ok := any(t).(interface{ hitsCount() int }); ok {
+			callFromContext(req.Context()).setHits(h.hitsCount())
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		if err := res.Body.Close(); err != nil {
-			log.WithError(err).Error("failed to close response body")
-		}
-	}()
 
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		log.WithFields(logrus.Fields{
-			"headers": res.Header,
-		}).Debugf("QW request failed: %s %s %d", req.Method, req.URL.String(), res.StatusCode)
+	return t, nil
+}
 
-		body, err := io.ReadAll(res.Body)
-		if err != nil {
-			log.WithError(err).Warn("cannot read error body")
-		}
-		msg := string(body)
+func RequestNoContent(doer Doer, log Logger, req *http.Request) error {
+	return send(doer, log, req, nil)
+}
 
-		m := &ErrorMsg{}
-		if err := json.Unmarshal(body, &m); err == nil {
-			msg = m.Message + m.Error
-		}
+func GetList[T any](doer Doer, log Logger, req *http.Request) ([]T, error) {
+	t := []T{}
 
-		return nil, fmt.Errorf("quickwit error: %d - %s", res.StatusCode, msg)
+	err := send(doer, log, req, func(body io.Reader) error {
+		return json.NewDecoder(body).Decode(&t)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	//payload, _ := io.ReadAll(res.Body)
-	//fmt.Printf("%+v\n", string(payload))
-
-	return t, json.NewDecoder(res.Body).Decode(t)
+	return t, nil
 }
 
-func RequestNoContent(log logrus.FieldLogger, req *http.Request) error {
-	res, err := http.DefaultClient.Do(req)
+// send performs the request and hands the body of successful responses to decode
+func send(doer Doer, log Logger, req *http.Request, decode func(body io.Reader) error) (err error) {
+	call := callFromContext(req.Context())
+	defer func() { call.end(err) }()
+
+	call.request(req)
+
+	res, err := do(doer, req)
 	if err != nil {
 		return err
 	}
 	defer func() {
 		if err := res.Body.Close(); err != nil {
-			log.WithError(err).Error("failed to close response body")
+			log.Error("failed to close response body", "error", err)
 		}
 	}()
 
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		body, err := io.ReadAll(res.Body)
-		if err != nil {
-			log.WithError(err).Warn("cannot read error body")
-		}
-		msg := string(body)
+	call.response(res)
 
-		m := &ErrorMsg{}
-		if err := json.Unmarshal(body, &m); err == nil {
-			msg = m.Message + m.Error
-		}
+	if err := checkResponse(log, req, res); err != nil {
+		return err
+	}
 
-		return fmt.Errorf("quickwit error: %d - %s", res.StatusCode, msg)
+	if decode == nil {
+		return nil
 	}
 
-	return nil
+	return decode(res.Body)
 }
 
-func GetList[T any](log logrus.FieldLogger, req *http.Request) ([]T, error) {
-	t := []T{}
+// checkResponse returns an *APIError built from the response body when the status code is not 2xx
+func checkResponse(log Logger, req *http.Request, res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+
+	log.Debug("quickwit request failed", "method", req.Method, "url", req.URL.String(), "status", res.StatusCode, "headers", res.Header)
 
-	res, err := http.DefaultClient.Do(req)
+	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, err
+		log.Warn("cannot read error body", "error", err)
+	}
+
+	apiErr := &APIError{
+		StatusCode: res.StatusCode,
+		Method:     req.Method,
+		Path:       req.URL.Path,
+	}
+	if op, ok := OperationFromContext(req.Context()); ok {
+		apiErr.Operation = op.Name
 	}
-	defer func() {
-		if err := res.Body.Close(); err != nil {
-			log.WithError(err).Error("failed to close response body")
-		}
-	}()
 
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return nil, fmt.Errorf("quickwit error: %d", res.StatusCode)
+	m := &ErrorMsg{}
+	if err := json.Unmarshal(body, &m); err == nil && (m.Message != "" || m.Error != "") {
+		apiErr.Message = m.Message
+		apiErr.ErrorMessage = m.Error
+	} else {
+		apiErr.Body = string(body)
 	}
 
-	return t, json.NewDecoder(res.Body).Decode(&t)
+	return apiErr
+}
+
+// do sends the request through the doer, tagging it with a request ID
+func do(doer Doer, req *http.Request) (*http.Response, error) {
+	if req.Header.Get(RequestIDHeader) == "" {
+		req.Header.Set(RequestIDHeader, newRequestID())
+	}
+
+	return doer.Do(req)
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
 func MustMarshall(i any) *bytes.Buffer {
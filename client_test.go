@@ -2,7 +2,11 @@ package quickwit
 
 import (
 	"context"
+	"log/slog"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -25,6 +29,14 @@ func TestClient(t *testing.T) {
 	// Create client with testcontainer endpoint
 	client := New(WithEndpoint(container.Endpoint))
 
+	t.Run("Health", func(t *testing.T) {
+		require.NoError(t, client.WaitUntilReady(ctx, WaitOptions{Timeout: 30 * time.Second}))
+
+		live, err := client.Livez(ctx)
+		require.NoError(t, err)
+		assert.True(t, live)
+	})
+
 	t.Run("Get Cluster", func(t *testing.T) {
 		cluster, err := client.GetCluster(ctx)
 		require.NoError(t, err)
@@ -34,10 +46,20 @@ func TestClient(t *testing.T) {
 		t.Logf("Cluster: %+v", cluster)
 	})
 
+	t.Run("Version", func(t *testing.T) {
+		v, err := client.Version(ctx)
+		require.NoError(t, err)
+		assert.NotEmpty(t, v.Build.Version)
+
+		caps, err := client.Capabilities(ctx)
+		require.NoError(t, err)
+		assert.NotEmpty(t, caps.IndexConfigVersion)
+	})
+
 	t.Run("Create Index", func(t *testing.T) {
+		// the config version is negotiated with the cluster
 		indexConfig := IndexConfig{
-			Version: "0.9",
-			ID:      "test-index",
+			ID: "test-index",
 			DocMapping: DocMapping{
 				Mode: "dynamic",
 				FieldMappings: []FieldMapping{
@@ -69,6 +91,9 @@ func TestClient(t *testing.T) {
 		require.NoError(t, err)
 		assert.NotNil(t, idx)
 		assert.Equal(t, "test-index", idx.Config.ID)
+
+		_, err = client.CreateIndex(ctx, indexConfig)
+		assert.ErrorIs(t, err, ErrIndexAlreadyExists)
 	})
 
 	t.Run("List Indexes", func(t *testing.T) {
@@ -132,7 +157,12 @@ func TestClient(t *testing.T) {
 
 		// Verify it's deleted
 		_, err = client.GetIndex(ctx, "delete-test-index")
-		assert.Error(t, err)
+		assert.ErrorIs(t, err, ErrIndexNotFound)
+
+		var apiErr *APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		assert.Equal(t, http.MethodGet, apiErr.Method)
 	})
 
 	t.Run("Describe Index", func(t *testing.T) {
@@ -163,6 +193,11 @@ func TestClient(t *testing.T) {
 		t.Skip("File sources are not allowed via API, use CLI command 'quickwit tool local-ingest'")
 	})
 
+	t.Run("Delete Missing Source", func(t *testing.T) {
+		err := client.DeleteSource(ctx, "test-index", "missing-source")
+		assert.ErrorIs(t, err, ErrSourceNotFound)
+	})
+
 	t.Run("Search", func(t *testing.T) {
 		// Search on the test-index (empty index, should return no results but no error)
 		result, err := client.Search(ctx, "test-index", "*")
@@ -171,6 +206,26 @@ func TestClient(t *testing.T) {
 		assert.Equal(t, 0, result.NumHits)
 	})
 
+	t.Run("Ingest", func(t *testing.T) {
+		docs := strings.NewReader(`{"timestamp": 1735689600, "message": "hello"}` + "\n" + `{"timestamp": 1735689601, "message": "world"}` + "\n")
+
+		res, err := client.Ingest(ctx, "test-index", docs)
+		require.NoError(t, err)
+		assert.Equal(t, 2, res.NumDocsForProcessing)
+	})
+
+	t.Run("Slog Handler", func(t *testing.T) {
+		err := EnsureLogIndex(ctx, client, "slog-logs", DefaultLogSchema())
+		require.NoError(t, err)
+
+		handler := NewSlogHandler(client, "slog-logs", SlogHandlerConfig{AddSource: true})
+		logger := slog.New(handler).With("service", "api").WithGroup("http")
+		logger.Info("request served", "status", 200, "path", "/")
+
+		require.NoError(t, handler.Close(ctx))
+		assert.Equal(t, uint64(1), handler.Stats().Sent)
+	})
+
 	t.Run("Get Elastic Endpoint", func(t *testing.T) {
 		cluster, err := client.GetElastic(ctx)
 		require.NoError(t, err)
@@ -0,0 +1,178 @@
+package quickwit
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+)
+
+type ClusterEventType string
+
+const (
+	NodeJoined           ClusterEventType = "node_joined"
+	NodeLeft             ClusterEventType = "node_left"
+	NodeReadinessChanged ClusterEventType = "node_readiness_changed"
+	NodeServicesChanged  ClusterEventType = "node_services_changed"
+	IndexingTaskMoved    ClusterEventType = "indexing_task_moved"
+)
+
+const clusterEventsBuffer = 16
+
+// DefaultClusterWatchInterval is used by WatchCluster when the interval is not positive
+const DefaultClusterWatchInterval = 10 * time.Second
+
+// ClusterEvent is a change between two successive cluster states
+type ClusterEvent struct {
+	Type ClusterEventType
+	// Node the event is about, for IndexingTaskMoved the node the task moved to
+	Node ChitchatID
+	// Generation of the previous incarnation when a NodeJoined is a restart
+	PreviousGenerationID uint64
+	Ready                bool
+	Services             []string
+	// Moved task and the node it left, set on IndexingTaskMoved
+	Task     *IndexingTask
+	FromNode string
+}
+
+// clusterView is the state of the members of the cluster compared between two polls
+type clusterView map[string]*nodeView
+
+type nodeView struct {
+	id       ChitchatID
+	ready    bool
+	services []string
+	tasks    []IndexingTask
+}
+
+// newClusterView keeps the latest generation of every node, nodes whose latest generation is dead are not members
+func newClusterView(cluster *Cluster) clusterView {
+	view := clusterView{}
+	for _, n := range cluster.LatestNodes() {
+		if n.Dead && !n.Ready && !n.Live {
+			continue
+		}
+
+		v := &nodeView{id: n.ChitchatID, ready: n.Ready, services: []string{}, tasks: []IndexingTask{}}
+		if n.State != nil {
+			v.ready = v.ready || n.State.IsReady()
+			v.services = n.State.EnabledServices()
+			v.tasks = n.State.IndexingTasks()
+		}
+		sort.Strings(v.services)
+		view[n.NodeID] = v
+	}
+
+	return view
+}
+
+// diffClusters returns the events turning prev into cur, nodes are visited in ID order
+func diffClusters(prev, cur clusterView) []ClusterEvent {
+	events := []ClusterEvent{}
+
+	ids := make([]string, 0, len(prev)+len(cur))
+	for id := range prev {
+		ids = append(ids, id)
+	}
+	for id := range cur {
+		if _, ok := prev[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		p, c := prev[id], cur[id]
+
+		switch {
+		case c == nil:
+			events = append(events, ClusterEvent{Type: NodeLeft, Node: p.id})
+		case p != nil && c.id.GenerationID < p.id.GenerationID:
+			// stale gossip about a previous incarnation, keep the known one
+			cur[id] = p
+		case p == nil:
+			events = append(events, ClusterEvent{Type: NodeJoined, Node: c.id, Ready: c.ready, Services: c.services})
+		case c.id.GenerationID != p.id.GenerationID:
+			events = append(events,
+				ClusterEvent{Type: NodeLeft, Node: p.id},
+				ClusterEvent{Type: NodeJoined, Node: c.id, PreviousGenerationID: p.id.GenerationID, Ready: c.ready, Services: c.services},
+			)
+		default:
+			if c.ready != p.ready {
+				events = append(events, ClusterEvent{Type: NodeReadinessChanged, Node: c.id, Ready: c.ready, Services: c.services})
+			}
+			if !slices.Equal(c.services, p.services) {
+				events = append(events, ClusterEvent{Type: NodeServicesChanged, Node: c.id, Ready: c.ready, Services: c.services})
+			}
+		}
+	}
+
+	previousNode := map[string]string{}
+	for id, p := range prev {
+		for _, task := range p.tasks {
+			previousNode[task.PipelineUID] = id
+		}
+	}
+	for _, id := range ids {
+		c := cur[id]
+		if c == nil {
+			continue
+		}
+		for _, task := range c.tasks {
+			from, ok := previousNode[task.PipelineUID]
+			if ok && from != id {
+				events = append(events, ClusterEvent{Type: IndexingTaskMoved, Node: c.id, Task: &task, FromNode: from})
+			}
+		}
+	}
+
+	return events
+}
+
+// WatchCluster polls the cluster state every interval and emits the changes on the returned channel,
+// which is closed once the context is done. The nodes of the first poll are emitted as NodeJoined events.
+// A restarted node is reported as NodeLeft for its previous generation then NodeJoined for the new one,
+// stale gossip about older generations is ignored. Poll errors are logged and the next poll retried.
+// A non-positive interval defaults to DefaultClusterWatchInterval.
+func (c *client) WatchCluster(ctx context.Context, interval time.Duration) <-chan ClusterEvent {
+	if interval <= 0 {
+		interval = DefaultClusterWatchInterval
+	}
+
+	events := make(chan ClusterEvent, clusterEventsBuffer)
+
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		view := clusterView{}
+		for {
+			cluster, err := c.GetCluster(ctx)
+			if err != nil && ctx.Err() == nil {
+				c.log.Warn("cannot poll quickwit cluster state", "error", err)
+			}
+			if err == nil {
+				next := newClusterView(cluster)
+				for _, event := range diffClusters(view, next) {
+					select {
+					case events <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+				view = next
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return events
+}
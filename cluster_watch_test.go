@@ -0,0 +1,106 @@
+package quickwit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// clusterJSON renders a cluster state from node descriptions: id, generation, readiness and task
+func clusterJSON(nodes ...[4]string) string {
+	ready, snapshots := []string{}, []string{}
+	for _, n := range nodes {
+		id := fmt.Sprintf(`{"node_id": %q, "generation_id": %s, "gossip_advertise_addr": "10.0.0.1:7280"}`, n[0], n[1])
+		if n[2] == "READY" {
+			ready = append(ready, id)
+		}
+		task := ""
+		if n[3] != "" {
+			task = fmt.Sprintf(`, "indexer.task:%s": {"value": "logs:01JKTRV3MF0000000000000000:_ingest-source:", "version": 1, "status": "Set"}`, n[3])
+		}
+		snapshots = append(snapshots, fmt.Sprintf(`{"chitchat_id": %s, "node_state": {"key_values": {
+			"readiness": {"value": %q, "version": 1, "status": "Set"},
+			"enabled_services": {"value": "indexer,searcher", "version": 1, "status": "Set"}%s
+		}}}`, id, n[2], task))
+	}
+
+	return fmt.Sprintf(`{"ready_nodes": [%s], "chitchat_state_snapshot": {"node_state_snapshots": [%s]}}`,
+		strings.Join(ready, ","), strings.Join(snapshots, ","))
+}
+
+func TestWatchCluster(t *testing.T) {
+	states := []string{
+		clusterJSON([4]string{"node-0", "1", "READY", "pipeline-a"}, [4]string{"node-1", "1", "READY", ""}),
+		// same state, nothing emitted
+		clusterJSON([4]string{"node-0", "1", "READY", "pipeline-a"}, [4]string{"node-1", "1", "READY", ""}),
+		// node-0 restarts and loses its task to node-1
+		clusterJSON([4]string{"node-0", "2", "NOT_READY", ""}, [4]string{"node-1", "1", "READY", "pipeline-a"}),
+		// stale gossip about the previous generation of node-0
+		clusterJSON([4]string{"node-0", "1", "READY", ""}, [4]string{"node-1", "1", "READY", "pipeline-a"}),
+		// node-0 gets ready, node-1 leaves
+		clusterJSON([4]string{"node-0", "2", "READY", ""}),
+	}
+
+	polls := atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := min(int(polls.Add(1))-1, len(states)-1)
+		_, _ = fmt.Fprint(w, states[i])
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c := New(WithEndpoint(srv.URL), WithLogger(NewNopLogger()))
+
+	events := []string{}
+	for event := range c.WatchCluster(ctx, time.Millisecond) {
+		desc := fmt.Sprintf("%s %s/%d", event.Type, event.Node.NodeID, event.Node.GenerationID)
+		if event.Type == IndexingTaskMoved {
+			desc += fmt.Sprintf(" %s from %s", event.Task.PipelineUID, event.FromNode)
+		}
+		events = append(events, desc)
+		if len(events) == 7 {
+			cancel()
+		}
+	}
+
+	assert.Equal(t, []string{
+		"node_joined node-0/1",
+		"node_joined node-1/1",
+		"node_left node-0/1",
+		"node_joined node-0/2",
+		"indexing_task_moved node-1/1 pipeline-a from node-0",
+		"node_readiness_changed node-0/2",
+		"node_left node-1/1",
+	}, events)
+}
+
+func TestWatchClusterDefaultInterval(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, clusterJSON([4]string{"node-0", "1", "READY", ""}))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c := New(WithEndpoint(srv.URL), WithLogger(NewNopLogger()))
+
+	// a zero interval must not panic, the first poll happens right away
+	events := c.WatchCluster(ctx, 0)
+	event := <-events
+	assert.Equal(t, NodeJoined, event.Type)
+	assert.Equal(t, "node-0", event.Node.NodeID)
+
+	cancel()
+	for range events {
+	}
+}
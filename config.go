@@ -0,0 +1,395 @@
+package quickwit
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables read by NewFromEnv and NewFromProfile
+const (
+	EnvEndpoint         = "QW_ENDPOINT"
+	EnvToken            = "QW_TOKEN"
+	EnvTokenFile        = "QW_TOKEN_FILE"
+	EnvUser             = "QW_USER"
+	EnvPassword         = "QW_PASSWORD"
+	EnvCAFile           = "QW_CA_FILE"
+	EnvCertFile         = "QW_CERT_FILE"
+	EnvKeyFile          = "QW_KEY_FILE"
+	EnvTLSServerName    = "QW_TLS_SERVER_NAME"
+	EnvTLSMinVersion    = "QW_TLS_MIN_VERSION"
+	EnvTLSInsecure      = "QW_TLS_INSECURE_SKIP_VERIFY"
+	EnvTimeout          = "QW_TIMEOUT"
+	EnvRetryMaxAttempts = "QW_RETRY_MAX_ATTEMPTS"
+	// Name of the profile used by NewFromProfile when none is given
+	EnvProfile = "QW_PROFILE"
+	// Path of the profile file, defaults to DefaultProfilePath()
+	EnvConfigFile = "QW_CONFIG"
+)
+
+var (
+	ErrInvalidConfig   = errors.New("invalid quickwit client configuration")
+	ErrProfileNotFound = errors.New("quickwit profile not found")
+)
+
+// Duration accepts Go duration strings ("10s", "1m30s") in profile files
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Profile describes how to reach a cluster, it is read from a profile file and the environment
+type Profile struct {
+	Endpoint  string `yaml:"endpoint" toml:"endpoint"`
+	Token     string `yaml:"token" toml:"token"`
+	TokenFile string `yaml:"token_file" toml:"token_file"`
+	User      string `yaml:"user" toml:"user"`
+	Password  string `yaml:"password" toml:"password"`
+	// Timeout of each HTTP request, zero means none
+	Timeout Duration      `yaml:"timeout" toml:"timeout"`
+	TLS     *ProfileTLS   `yaml:"tls" toml:"tls"`
+	Retry   *ProfileRetry `yaml:"retry" toml:"retry"`
+}
+
+type ProfileTLS struct {
+	CAFile     string `yaml:"ca_file" toml:"ca_file"`
+	CertFile   string `yaml:"cert_file" toml:"cert_file"`
+	KeyFile    string `yaml:"key_file" toml:"key_file"`
+	ServerName string `yaml:"server_name" toml:"server_name"`
+	// "1.2" or "1.3"
+	MinVersion         string `yaml:"min_version" toml:"min_version"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" toml:"insecure_skip_verify"`
+}
+
+type ProfileRetry struct {
+	MaxAttempts    int      `yaml:"max_attempts" toml:"max_attempts"`
+	InitialBackoff Duration `yaml:"initial_backoff" toml:"initial_backoff"`
+	MaxBackoff     Duration `yaml:"max_backoff" toml:"max_backoff"`
+}
+
+// ProfileFile holds named profiles, the default one is used when no name is given
+type ProfileFile struct {
+	Default  string             `yaml:"default" toml:"default"`
+	Profiles map[string]Profile `yaml:"profiles" toml:"profiles"`
+}
+
+// DefaultProfilePath returns $XDG_CONFIG_HOME/quickwit/profiles.yaml or its platform equivalent
+func DefaultProfilePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(".quickwit", "profiles.yaml")
+	}
+	return filepath.Join(dir, "quickwit", "profiles.yaml")
+}
+
+// LoadProfileFile reads a YAML (.yaml, .yml) or TOML (.toml) profile file, unknown fields are rejected
+func LoadProfileFile(path string) (*ProfileFile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read profile file: %w", err)
+	}
+
+	f := ProfileFile{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		md, err := toml.Decode(string(b), &f)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidConfig, path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("%w: %s: unknown field %s", ErrInvalidConfig, path, undecoded[0])
+		}
+	case ".yaml", ".yml", "":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err := dec.Decode(&f); err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidConfig, path, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s: unsupported profile file format", ErrInvalidConfig, path)
+	}
+
+	return &f, nil
+}
+
+// NewFromEnv creates a client from the QW_* environment variables.
+// Options given as arguments are applied last and take precedence.
+func NewFromEnv(opts ...clientOption) (Client, error) {
+	p := Profile{}
+	if err := p.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	return newFromProfile(p, opts)
+}
+
+// NewFromProfile creates a client from a named profile of the profile file (QW_CONFIG or DefaultProfilePath).
+// When name is empty, QW_PROFILE then the file default are used.
+// Precedence, from lowest to highest: profile file, QW_* environment variables, options given as arguments.
+func NewFromProfile(name string, opts ...clientOption) (Client, error) {
+	path := os.Getenv(EnvConfigFile)
+	if path == "" {
+		path = DefaultProfilePath()
+	}
+
+	f, err := LoadProfileFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := f.Profile(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	return newFromProfile(p, opts)
+}
+
+// Profile returns the named profile, falling back to QW_PROFILE then the file default when name is empty
+func (f *ProfileFile) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = os.Getenv(EnvProfile)
+	}
+	if name == "" {
+		name = f.Default
+	}
+	if name == "" {
+		return Profile{}, fmt.Errorf("%w: no profile name given and no default profile", ErrProfileNotFound)
+	}
+
+	p, ok := f.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+	return p, nil
+}
+
+func newFromProfile(p Profile, opts []clientOption) (Client, error) {
+	profileOpts, err := p.Options()
+	if err != nil {
+		return nil, err
+	}
+
+	return New(append(profileOpts, opts...)...), nil
+}
+
+// applyEnv overrides the profile with the QW_* environment variables which are set
+func (p *Profile) applyEnv() error {
+	errs := []error{}
+
+	setString := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+	tlsConfig := func() *ProfileTLS {
+		if p.TLS == nil {
+			p.TLS = &ProfileTLS{}
+		}
+		return p.TLS
+	}
+
+	// credentials set in the environment replace the profile ones instead of conflicting with them
+	isSet := func(names ...string) bool {
+		for _, name := range names {
+			if _, ok := os.LookupEnv(name); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if isSet(EnvToken, EnvTokenFile) {
+		p.Token, p.TokenFile, p.User, p.Password = "", "", "", ""
+	}
+	if isSet(EnvUser, EnvPassword) {
+		p.Token, p.TokenFile = "", ""
+	}
+
+	setString(EnvEndpoint, &p.Endpoint)
+	setString(EnvToken, &p.Token)
+	setString(EnvTokenFile, &p.TokenFile)
+	setString(EnvUser, &p.User)
+	setString(EnvPassword, &p.Password)
+
+	for name, dst := range map[string]func(*ProfileTLS) *string{
+		EnvCAFile:        func(t *ProfileTLS) *string { return &t.CAFile },
+		EnvCertFile:      func(t *ProfileTLS) *string { return &t.CertFile },
+		EnvKeyFile:       func(t *ProfileTLS) *string { return &t.KeyFile },
+		EnvTLSServerName: func(t *ProfileTLS) *string { return &t.ServerName },
+		EnvTLSMinVersion: func(t *ProfileTLS) *string { return &t.MinVersion },
+	} {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst(tlsConfig()) = v
+		}
+	}
+
+	if v, ok := os.LookupEnv(EnvTLSInsecure); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", EnvTLSInsecure, err))
+		}
+		tlsConfig().InsecureSkipVerify = b
+	}
+
+	if v, ok := os.LookupEnv(EnvTimeout); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", EnvTimeout, err))
+		}
+		p.Timeout = Duration(d)
+	}
+
+	if v, ok := os.LookupEnv(EnvRetryMaxAttempts); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", EnvRetryMaxAttempts, err))
+		}
+		if p.Retry == nil {
+			p.Retry = &ProfileRetry{}
+		}
+		p.Retry.MaxAttempts = n
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	return nil
+}
+
+// Validate reports every problem of the profile at once, the error wraps ErrInvalidConfig
+func (p Profile) Validate() error {
+	errs := []error{}
+
+	if p.Endpoint != "" {
+		u, err := url.Parse(p.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("endpoint %q must look like http://localhost:7280", p.Endpoint))
+		}
+	}
+
+	if p.Token != "" && p.TokenFile != "" {
+		errs = append(errs, errors.New("token and token_file are mutually exclusive"))
+	}
+	if (p.Token != "" || p.TokenFile != "") && (p.User != "" || p.Password != "") {
+		errs = append(errs, errors.New("bearer token and basic auth are mutually exclusive"))
+	}
+	if p.Password != "" && p.User == "" {
+		errs = append(errs, errors.New("password is set without user"))
+	}
+
+	if p.Timeout < 0 {
+		errs = append(errs, errors.New("timeout must not be negative"))
+	}
+
+	if p.TLS != nil {
+		if (p.TLS.CertFile == "") != (p.TLS.KeyFile == "") {
+			errs = append(errs, errors.New("tls cert_file and key_file must be set together"))
+		}
+		if _, err := tlsVersion(p.TLS.MinVersion); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if p.Retry != nil {
+		if p.Retry.MaxAttempts < 0 {
+			errs = append(errs, errors.New("retry max_attempts must not be negative"))
+		}
+		if p.Retry.InitialBackoff < 0 || p.Retry.MaxBackoff < 0 {
+			errs = append(errs, errors.New("retry backoffs must not be negative"))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	return nil
+}
+
+// Options validates the profile, loads its TLS files and returns the matching client options
+func (p Profile) Options() ([]clientOption, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts := []clientOption{}
+
+	if p.Endpoint != "" {
+		opts = append(opts, WithEndpoint(strings.TrimSuffix(p.Endpoint, "/")))
+	}
+
+	switch {
+	case p.Token != "":
+		opts = append(opts, WithBearerToken(p.Token))
+	case p.TokenFile != "":
+		opts = append(opts, WithTokenSource(NewFileTokenSource(p.TokenFile)))
+	case p.User != "":
+		opts = append(opts, WithBasicAuth(p.User, p.Password))
+	}
+
+	if p.Timeout > 0 {
+		opts = append(opts, WithHttpClient(&http.Client{Timeout: time.Duration(p.Timeout)}))
+	}
+
+	if p.TLS != nil {
+		minVersion, _ := tlsVersion(p.TLS.MinVersion)
+		tlsConfig := TLSConfig{
+			CAFile:             p.TLS.CAFile,
+			CertFile:           p.TLS.CertFile,
+			KeyFile:            p.TLS.KeyFile,
+			ServerName:         p.TLS.ServerName,
+			MinVersion:         minVersion,
+			InsecureSkipVerify: p.TLS.InsecureSkipVerify,
+		}
+		if err := tlsConfig.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+		}
+		opts = append(opts, WithTLS(tlsConfig))
+	}
+
+	if p.Retry != nil {
+		opts = append(opts, WithRetry(RetryPolicy{
+			MaxAttempts:    p.Retry.MaxAttempts,
+			InitialBackoff: time.Duration(p.Retry.InitialBackoff),
+			MaxBackoff:     time.Duration(p.Retry.MaxBackoff),
+		}))
+	}
+
+	return opts, nil
+}
+
+func tlsVersion(v string) (uint16, error) {
+	switch v {
+	case "":
+		return 0, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("tls min_version %q must be 1.2 or 1.3", v)
+	}
+}
@@ -0,0 +1,233 @@
+package quickwit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidConfigFile = errors.New("quickwit: invalid config file")
+
+// LoadIndexConfig reads an index config file in the format accepted by the Quickwit CLI, see ReadIndexConfig
+func LoadIndexConfig(path string) (*IndexConfig, error) {
+	return loadConfigFile(path, ReadIndexConfig)
+}
+
+// ReadIndexConfig reads a YAML or JSON index config. ${VAR} and ${VAR:-default} are replaced by environment
+// variables, unknown fields are rejected and unset values get the Quickwit defaults
+func ReadIndexConfig(r io.Reader) (*IndexConfig, error) {
+	cfg, err := readConfig[IndexConfig](r)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.DocMapping.Mode == "" {
+		cfg.DocMapping.Mode = "dynamic"
+	}
+	return cfg, nil
+}
+
+// LoadSourceConfig reads a source config file, see ReadIndexConfig
+func LoadSourceConfig(path string) (*SourceConfig, error) {
+	return loadConfigFile(path, ReadSourceConfig)
+}
+
+func ReadSourceConfig(r io.Reader) (*SourceConfig, error) {
+	return readConfig[SourceConfig](r)
+}
+
+// LoadNodeConfig reads a node config file (quickwit.yaml), see ReadIndexConfig
+func LoadNodeConfig(path string) (*NodeConfig, error) {
+	return loadConfigFile(path, ReadNodeConfig)
+}
+
+func ReadNodeConfig(r io.Reader) (*NodeConfig, error) {
+	return readConfig[NodeConfig](r)
+}
+
+// MarshalYAML encodes an IndexConfig, a SourceConfig or a NodeConfig in the format read by the Load functions
+func MarshalYAML(v any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func loadConfigFile[T any](path string, read func(io.Reader) (*T, error)) (*T, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	cfg, err := read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func readConfig[T any](r io.Reader) (*T, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config: %w", err)
+	}
+
+	b, err = interpolateEnv(b)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON goes through YAML to share the strict decoding
+	if trimmed := bytes.TrimSpace(b); bytes.HasPrefix(trimmed, []byte("{")) {
+		var v any
+		if err := json.Unmarshal(trimmed, &v); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidConfigFile, err)
+		}
+		if b, err = yaml.Marshal(v); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidConfigFile, err)
+		}
+	}
+
+	cfg := new(T)
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: empty config", ErrInvalidConfigFile)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfigFile, err)
+	}
+	return cfg, nil
+}
+
+var envVarPattern = regexp.MustCompile(`\$\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*(?::-([^}]*))?\}`)
+
+// interpolateEnv replaces ${VAR} and ${VAR:-default} like Quickwit does, commented lines are left untouched
+func interpolateEnv(b []byte) ([]byte, error) {
+	lines := strings.Split(string(b), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		var missing []string
+		lines[i] = envVarPattern.ReplaceAllStringFunc(line, func(match string) string {
+			groups := envVarPattern.FindStringSubmatch(match)
+			if v, ok := os.LookupEnv(groups[1]); ok {
+				return v
+			}
+			if strings.Contains(match, ":-") {
+				return strings.TrimSpace(groups[2])
+			}
+			missing = append(missing, groups[1])
+			return match
+		})
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("%w: line %d: environment variable %s is not set", ErrInvalidConfigFile, i+1, missing[0])
+		}
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// UnmarshalYAML defaults indexed and stored to true like Quickwit
+func (f *FieldMapping) UnmarshalYAML(node *yaml.Node) error {
+	type plain FieldMapping
+	p := plain{Indexed: true, Stored: true}
+
+	// the decoder strictness does not reach custom unmarshalers
+	if err := checkKnownFields(node, FieldMapping{}); err != nil {
+		return err
+	}
+	if err := node.Decode(&p); err != nil {
+		return err
+	}
+
+	*f = FieldMapping(p)
+	return nil
+}
+
+// UnmarshalJSON defaults indexed and stored to true like UnmarshalYAML
+func (f *FieldMapping) UnmarshalJSON(b []byte) error {
+	type plain FieldMapping
+	p := plain{Indexed: true, Stored: true}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+
+	*f = FieldMapping(p)
+	return nil
+}
+
+// MarshalJSON leaves out indexed and stored for object and concatenate fields, Quickwit rejects them
+func (f FieldMapping) MarshalJSON() ([]byte, error) {
+	type plain FieldMapping
+	if !f.composite() {
+		return json.Marshal(plain(f))
+	}
+	return json.Marshal(struct {
+		plain
+		Indexed *bool `json:"indexed,omitempty"`
+		Stored  *bool `json:"stored,omitempty"`
+	}{plain: plain(f)})
+}
+
+// MarshalYAML leaves out indexed and stored for object and concatenate fields, like MarshalJSON
+func (f FieldMapping) MarshalYAML() (any, error) {
+	type plain FieldMapping
+	if !f.composite() {
+		return plain(f), nil
+	}
+
+	node := &yaml.Node{}
+	if err := node.Encode(plain(f)); err != nil {
+		return nil, err
+	}
+	content := []*yaml.Node{}
+	for i := 0; i < len(node.Content); i += 2 {
+		if key := node.Content[i].Value; key != "indexed" && key != "stored" {
+			content = append(content, node.Content[i], node.Content[i+1])
+		}
+	}
+	node.Content = content
+	return node, nil
+}
+
+func (f FieldMapping) composite() bool {
+	return f.Type == "object" || f.Type == "concatenate"
+}
+
+func checkKnownFields(node *yaml.Node, v any) error {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	t := reflect.TypeOf(v)
+	known := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		known[name] = true
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if !known[key.Value] {
+			return fmt.Errorf("line %d: field %s not found in type quickwit.%s", key.Line, key.Value, t.Name())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package quickwit
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const indexConfigYAML = `version: 0.8
+# index_uri: ${UNSET_IN_COMMENT}
+index_id: ${QW_TEST_TENANT}-logs
+index_uri: ${QW_TEST_BUCKET:-s3://default-bucket}/logs
+doc_mapping:
+  timestamp_field: timestamp
+  field_mappings:
+    - name: timestamp
+      type: datetime
+      fast: true
+      input_formats: [unix_timestamp]
+    - name: body
+      type: text
+      tokenizer: default
+      record: position
+      stored: false
+search_settings:
+  default_search_fields: [body]
+retention:
+  period: 30 days
+  schedule: daily
+`
+
+func TestLoadIndexConfig(t *testing.T) {
+	t.Setenv("QW_TEST_TENANT", "acme")
+
+	path := filepath.Join(t.TempDir(), "index.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(indexConfigYAML), 0o600))
+
+	cfg, err := LoadIndexConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "acme-logs", cfg.ID)
+	assert.Equal(t, "s3://default-bucket/logs", cfg.URI)
+	assert.Equal(t, "dynamic", cfg.DocMapping.Mode)
+	assert.Equal(t, &IndexRetention{Period: "30 days", Schedule: "daily"}, cfg.Retention)
+	require.Len(t, cfg.DocMapping.FieldMappings, 2)
+	assert.True(t, cfg.DocMapping.FieldMappings[0].Indexed)
+	assert.True(t, cfg.DocMapping.FieldMappings[0].Stored)
+	assert.False(t, cfg.DocMapping.FieldMappings[1].Stored)
+
+	// round trip
+	b, err := MarshalYAML(cfg)
+	require.NoError(t, err)
+	again, err := ReadIndexConfig(bytes.NewReader(b))
+	require.NoError(t, err)
+	assert.Equal(t, cfg, again)
+
+	// JSON
+	again, err = ReadIndexConfig(bytes.NewReader(MustMarshall(cfg).Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, cfg, again)
+
+	t.Run("Unknown Field", func(t *testing.T) {
+		_, err := ReadIndexConfig(strings.NewReader("index_id: logs\nretention:\n  perod: 30 days\n"))
+		assert.ErrorIs(t, err, ErrInvalidConfigFile)
+
+		_, err = ReadIndexConfig(strings.NewReader("index_id: logs\ndoc_mapping:\n  field_mappings:\n    - name: body\n      tokeniser: raw\n"))
+		assert.ErrorIs(t, err, ErrInvalidConfigFile)
+		assert.ErrorContains(t, err, "line 5: field tokeniser not found in type quickwit.FieldMapping")
+	})
+
+	t.Run("Missing Env", func(t *testing.T) {
+		_, err := ReadIndexConfig(strings.NewReader("index_id: ${QW_TEST_MISSING}\n"))
+		assert.ErrorIs(t, err, ErrInvalidConfigFile)
+	})
+}
+
+func TestLoadQuickwitIndexConfigs(t *testing.T) {
+	t.Run("Tutorial", func(t *testing.T) {
+		cfg, err := LoadIndexConfig("testdata/hdfs-logs-index-config.yaml")
+		require.NoError(t, err)
+		require.Len(t, cfg.DocMapping.FieldMappings, 5)
+
+		resource := cfg.DocMapping.FieldMappings[4]
+		assert.Equal(t, "object", resource.Type)
+		require.Len(t, resource.FieldMappings, 1)
+		assert.Equal(t, "service", resource.FieldMappings[0].Name)
+		assert.True(t, resource.FieldMappings[0].Indexed)
+
+		// object fields are sent without indexed and stored
+		var doc map[string]any
+		require.NoError(t, json.Unmarshal(MustMarshall(resource).Bytes(), &doc))
+		assert.NotContains(t, doc, "indexed")
+		assert.NotContains(t, doc, "stored")
+
+		b, err := MarshalYAML(cfg)
+		require.NoError(t, err)
+		raw := struct {
+			DocMapping struct {
+				FieldMappings []map[string]any `yaml:"field_mappings"`
+			} `yaml:"doc_mapping"`
+		}{}
+		require.NoError(t, yaml.Unmarshal(b, &raw))
+		assert.NotContains(t, raw.DocMapping.FieldMappings[4], "indexed")
+		assert.Contains(t, raw.DocMapping.FieldMappings[3], "indexed")
+		again, err := ReadIndexConfig(bytes.NewReader(b))
+		require.NoError(t, err)
+		assert.Equal(t, cfg, again)
+	})
+
+	t.Run("Dynamic Mapping", func(t *testing.T) {
+		cfg, err := ReadIndexConfig(strings.NewReader(`version: 0.9
+index_id: logs
+doc_mapping:
+  mode: dynamic
+  doc_mapping_uid: 01HVKHQ3ZJ5C0XBRHR9ZZJE5K4
+  store_document_size: true
+  dynamic_mapping:
+    indexed: true
+    stored: false
+    tokenizer: default
+    record: basic
+    expand_dots: true
+    fast: true
+  field_mappings:
+    - name: message
+      type: concatenate
+      concatenate_fields: [body, attributes]
+      include_dynamic_fields: true
+indexing_settings:
+  merge_policy:
+    type: limit_merge
+    max_merge_ops: 3
+  resources:
+    heap_size: 2GB
+    max_merge_write_throughput: 80mb
+`))
+		require.NoError(t, err)
+		require.NotNil(t, cfg.DocMapping.DynamicMapping)
+		assert.Equal(t, new(bool), cfg.DocMapping.DynamicMapping.Stored)
+		assert.Equal(t, "default", cfg.DocMapping.DynamicMapping.Tokenizer)
+		assert.True(t, cfg.DocMapping.StoreDocumentSize)
+		assert.Equal(t, []string{"body", "attributes"}, cfg.DocMapping.FieldMappings[0].ConcatenateFields)
+		assert.Equal(t, 3, cfg.IndexingSettings.MergePolicy.MaxMergeOps)
+		assert.Equal(t, "80mb", cfg.IndexingSettings.Resources.MaxMergeWriteThroughput)
+	})
+}
+
+func TestLoadSourceAndNodeConfig(t *testing.T) {
+	t.Setenv("QW_TEST_PULSAR_TOKEN", "secret")
+
+	src, err := ReadSourceConfig(strings.NewReader(`version: 0.8
+source_id: pulsar
+source_type: pulsar
+num_pipelines: 2
+params:
+  address: pulsar://pulsar:6650
+  topics: [logs]
+  authentication:
+    token: ${QW_TEST_PULSAR_TOKEN}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 2, src.PipelineCount)
+	assert.Equal(t, map[string]any{"token": "secret"}, src.Params["authentication"])
+
+	b, err := MarshalYAML(src)
+	require.NoError(t, err)
+	again, err := ReadSourceConfig(bytes.NewReader(b))
+	require.NoError(t, err)
+	assert.Equal(t, src, again)
+
+	node, err := ReadNodeConfig(strings.NewReader(`version: 0.8
+node_id: searcher-0
+enabled_services: [searcher]
+rest:
+  listen_port: 7280
+peer_seeds: [quickwit-0:7280]
+searcher:
+  fast_field_cache_capacity: 1G
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 7280, node.Rest.ListenPort)
+	assert.Equal(t, "1G", node.Searcher["fast_field_cache_capacity"])
+
+	_, err = ReadNodeConfig(strings.NewReader("version: 0.8\nrest_listen_prot: 7280\n"))
+	assert.ErrorIs(t, err, ErrInvalidConfigFile)
+}
@@ -0,0 +1,144 @@
+package quickwit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileFile(t *testing.T) {
+	dir := t.TempDir()
+
+	caPath := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caPath, newTestCert(t, "ca", nil).certPEM, 0o600))
+
+	yamlPath := filepath.Join(dir, "profiles.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`
+default: local
+profiles:
+  local:
+    endpoint: http://localhost:7280
+  prod:
+    endpoint: https://quickwit.example.com
+    token_file: /run/secrets/quickwit
+    timeout: 10s
+    tls:
+      ca_file: `+caPath+`
+      min_version: "1.3"
+    retry:
+      max_attempts: 5
+`), 0o600))
+
+	tomlPath := filepath.Join(dir, "profiles.toml")
+	require.NoError(t, os.WriteFile(tomlPath, []byte(`
+[profiles.prod]
+endpoint = "https://quickwit.example.com"
+timeout = "10s"
+`), 0o600))
+
+	t.Run("YAML", func(t *testing.T) {
+		f, err := LoadProfileFile(yamlPath)
+		require.NoError(t, err)
+
+		p, err := f.Profile("")
+		require.NoError(t, err)
+		assert.Equal(t, "http://localhost:7280", p.Endpoint)
+
+		p, err = f.Profile("prod")
+		require.NoError(t, err)
+		assert.Equal(t, Duration(10*time.Second), p.Timeout)
+		assert.Equal(t, "1.3", p.TLS.MinVersion)
+		assert.Equal(t, 5, p.Retry.MaxAttempts)
+		assert.NoError(t, p.Validate())
+
+		_, err = f.Profile("staging")
+		assert.ErrorIs(t, err, ErrProfileNotFound)
+	})
+
+	t.Run("TOML", func(t *testing.T) {
+		f, err := LoadProfileFile(tomlPath)
+		require.NoError(t, err)
+
+		p, err := f.Profile("prod")
+		require.NoError(t, err)
+		assert.Equal(t, "https://quickwit.example.com", p.Endpoint)
+		assert.Equal(t, Duration(10*time.Second), p.Timeout)
+	})
+
+	t.Run("Unknown Field", func(t *testing.T) {
+		path := filepath.Join(dir, "typo.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("profiles:\n  local:\n    endpiont: http://localhost:7280\n"), 0o600))
+
+		_, err := LoadProfileFile(path)
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+	})
+
+	t.Run("Env Overrides Profile", func(t *testing.T) {
+		t.Setenv(EnvConfigFile, yamlPath)
+		t.Setenv(EnvProfile, "prod")
+		t.Setenv(EnvEndpoint, "https://other.example.com")
+		t.Setenv(EnvTimeout, "2s")
+
+		f, err := LoadProfileFile(yamlPath)
+		require.NoError(t, err)
+		p, err := f.Profile("")
+		require.NoError(t, err)
+		require.NoError(t, p.applyEnv())
+
+		assert.Equal(t, "https://other.example.com", p.Endpoint)
+		assert.Equal(t, Duration(2*time.Second), p.Timeout)
+		assert.Equal(t, "/run/secrets/quickwit", p.TokenFile)
+
+		c, err := NewFromProfile("")
+		require.NoError(t, err)
+		assert.Equal(t, "https://other.example.com", c.(*client).endpoint)
+
+		c, err = NewFromProfile("", WithEndpoint("http://explicit:7280"))
+		require.NoError(t, err)
+		assert.Equal(t, "http://explicit:7280", c.(*client).endpoint)
+	})
+
+	t.Run("Env Credentials Override Profile", func(t *testing.T) {
+		t.Setenv(EnvConfigFile, yamlPath)
+		t.Setenv(EnvToken, "env-token")
+
+		c, err := NewFromProfile("prod")
+		require.NoError(t, err, "QW_TOKEN replaces the profile token_file")
+		assert.NotNil(t, c)
+
+		p := Profile{TokenFile: "/run/secrets/quickwit"}
+		require.NoError(t, p.applyEnv())
+		assert.Equal(t, Profile{Token: "env-token"}, p)
+
+		p = Profile{User: "profile-user", Password: "profile-password"}
+		require.NoError(t, p.applyEnv())
+		assert.Equal(t, Profile{Token: "env-token"}, p)
+		assert.NoError(t, p.Validate())
+	})
+
+	t.Run("Env Basic Auth Overrides Profile Token", func(t *testing.T) {
+		t.Setenv(EnvUser, "env-user")
+
+		p := Profile{TokenFile: "/run/secrets/quickwit", Password: "profile-password"}
+		require.NoError(t, p.applyEnv())
+		assert.Equal(t, Profile{User: "env-user", Password: "profile-password"}, p)
+		assert.NoError(t, p.Validate())
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		t.Setenv(EnvEndpoint, "localhost:7280")
+		t.Setenv(EnvToken, "token")
+		t.Setenv(EnvUser, "user")
+		t.Setenv(EnvTLSMinVersion, "1.0")
+
+		_, err := NewFromEnv()
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "endpoint")
+		assert.ErrorContains(t, err, "mutually exclusive")
+		assert.ErrorContains(t, err, "min_version")
+	})
+}
@@ -1,3 +1,6 @@
 package quickwit
 
 const DefaultEndpoint = "http://localhost:7280"
+
+// RequestIDHeader carries the ID generated for each request, it is logged along with the request outcome
+const RequestIDHeader = "X-Request-Id"
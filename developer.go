@@ -0,0 +1,251 @@
+package quickwit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Developer endpoints, CPU profiling needs a node built with the pprof feature
+// and heap profiling one built with the jemalloc-profiled feature
+const (
+	DevLogLevelPath       = "/api/developer/log_level"
+	DevPprofStartPath     = "/api/developer/pprof/start"
+	DevPprofFlamegraph    = "/api/developer/pprof/flamegraph"
+	DevHeapProfilePath    = "/api/developer/heap_prof"
+	DefaultCPUProfileTime = 30 * time.Second
+	DefaultCPUSamplingHz  = 100
+
+	maxFlamegraphPolls = 30
+)
+
+type CPUProfileOptions struct {
+	// Profiling duration, defaults to 30s
+	Duration time.Duration
+	// Sampling frequency, defaults to 100Hz
+	SamplingHz int
+}
+
+func (o CPUProfileOptions) withDefaults() CPUProfileOptions {
+	if o.Duration <= 0 {
+		o.Duration = DefaultCPUProfileTime
+	}
+	if o.SamplingHz <= 0 {
+		o.SamplingHz = DefaultCPUSamplingHz
+	}
+	return o
+}
+
+type ProfileKind string
+
+const (
+	ProfileCPU  ProfileKind = "cpu"
+	ProfileHeap ProfileKind = "heap"
+)
+
+type ProfileNodesOptions struct {
+	Kind ProfileKind
+	CPU  CPUProfileOptions
+	// Nodes to profile, defaults to every node which is not dead
+	Select func(ClusterNode) bool
+}
+
+// NodeProfile is the outcome of the profiling of a node
+type NodeProfile struct {
+	NodeID   string
+	Endpoint string
+	// File the profile was written to
+	Path string
+	Err  error
+}
+
+// SetLogLevel changes the log filter of the node answering at runtime, e.g. "info,quickwit_search=debug"
+func (c *client) SetLogLevel(ctx context.Context, filter string) error {
+	req, err := c.newRequest(ctx, Operation{Name: OpSetLogLevel}, http.MethodPost, DevLogLevelPath+"?filter="+url.QueryEscape(filter), nil)
+	if err != nil {
+		return err
+	}
+
+	return RequestNoContent(c.doer, c.log, req)
+}
+
+// CPUProfile profiles the node answering for opts.Duration, rounded up to the second, and writes the resulting flamegraph (SVG) to w.
+// With a node pool, the profiling is pinned to one node so that the flamegraph is fetched from the node profiling.
+func (c *client) CPUProfile(ctx context.Context, w io.Writer, opts CPUProfileOptions) error {
+	opts = opts.withDefaults()
+	seconds := int(math.Ceil(opts.Duration.Seconds()))
+
+	if _, ok := targetNodeFromContext(ctx); !ok && c.pool != nil {
+		endpoint, err := c.pool.pin()
+		if err != nil {
+			return err
+		}
+		ctx = TargetNode(ctx, endpoint)
+	}
+
+	query := url.Values{}
+	query.Set("duration", strconv.Itoa(seconds))
+	query.Set("sampling", strconv.Itoa(opts.SamplingHz))
+
+	req, err := c.newRequest(ctx, Operation{Name: OpCPUProfile}, http.MethodGet, DevPprofStartPath+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if err := RequestNoContent(c.doer, c.log, req); err != nil {
+		return fmt.Errorf("cannot start CPU profiling: %w", err)
+	}
+
+	// the flamegraph is available once the profiling is over, the node answers 500 meanwhile
+	wait := time.Duration(seconds) * time.Second
+	for attempt := 0; ; attempt++ {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		req, err := c.newRequest(ctx, Operation{Name: OpFlamegraph}, http.MethodGet, DevPprofFlamegraph, nil)
+		if err != nil {
+			return err
+		}
+
+		err = send(c.doer, c.log, req, func(body io.Reader) error {
+			_, err := io.Copy(w, body)
+			return err
+		})
+		apiErr := &APIError{}
+		if !errors.As(err, &apiErr) || apiErr.StatusCode < 500 || apiErr.StatusCode == http.StatusNotImplemented || attempt >= maxFlamegraphPolls {
+			return err
+		}
+
+		// still profiling
+		wait = time.Second
+	}
+}
+
+// HeapProfile writes the heap profile of the node answering to w
+func (c *client) HeapProfile(ctx context.Context, w io.Writer) error {
+	req, err := c.newRequest(ctx, Operation{Name: OpHeapProfile}, http.MethodGet, DevHeapProfilePath, nil)
+	if err != nil {
+		return err
+	}
+
+	return send(c.doer, c.log, req, func(body io.Reader) error {
+		_, err := io.Copy(w, body)
+		return err
+	})
+}
+
+// ProfileNodes profiles the nodes of the cluster concurrently and writes one file per node in dir:
+// <node_id>-cpu.svg or <node_id>-heap.prof
+func (c *client) ProfileNodes(ctx context.Context, dir string, opts ProfileNodesOptions) ([]NodeProfile, error) {
+	if opts.Kind == "" {
+		opts.Kind = ProfileCPU
+	}
+	if opts.Kind != ProfileCPU && opts.Kind != ProfileHeap {
+		return nil, fmt.Errorf("unknown profile kind %q", opts.Kind)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	nodes, err := c.nodeEndpoints(ctx, opts.Select)
+	if err != nil {
+		return nil, err
+	}
+
+	wg := sync.WaitGroup{}
+	for i := range nodes {
+		if nodes[i].Err != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(p *NodeProfile) {
+			defer wg.Done()
+
+			ext := map[ProfileKind]string{ProfileCPU: "cpu.svg", ProfileHeap: "heap.prof"}[opts.Kind]
+			p.Path = filepath.Join(dir, fmt.Sprintf("%s-%s", p.NodeID, ext))
+			p.Err = writeProfile(p.Path, func(w io.Writer) error {
+				nodeCtx := TargetNode(ctx, p.Endpoint)
+				if opts.Kind == ProfileHeap {
+					return c.HeapProfile(nodeCtx, w)
+				}
+				return c.CPUProfile(nodeCtx, w, opts.CPU)
+			})
+		}(&nodes[i])
+	}
+	wg.Wait()
+
+	return nodes, nil
+}
+
+// writeProfile writes to a temporary file renamed once the profile is complete
+func writeProfile(path string, profile func(w io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if err := profile(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// nodeEndpoints returns the REST endpoint of the latest incarnation of the selected nodes which are not dead
+func (c *client) nodeEndpoints(ctx context.Context, selectNode func(ClusterNode) bool) ([]NodeProfile, error) {
+	cluster, err := c.GetCluster(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme, restPort, err := c.nodeScheme()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []NodeProfile{}
+	for _, n := range cluster.LatestNodes() {
+		if n.Dead {
+			continue
+		}
+		if selectNode != nil && !selectNode(n) {
+			continue
+		}
+
+		p := NodeProfile{NodeID: n.NodeID}
+		p.Endpoint, p.Err = nodeEndpoint(scheme, n.GossipAdvertiseAddr, restPort)
+		nodes = append(nodes, p)
+	}
+
+	return nodes, nil
+}
+
+// Searchers selects the nodes running the searcher service, for ProfileNodesOptions.Select
+func Searchers(n ClusterNode) bool {
+	return n.State != nil && n.State.HasService(ServiceSearcher)
+}
+
+// Indexers selects the nodes running the indexer service, for ProfileNodesOptions.Select
+func Indexers(n ClusterNode) bool {
+	return n.State != nil && n.State.HasService(ServiceIndexer)
+}
@@ -0,0 +1,126 @@
+package quickwit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeveloper(t *testing.T) {
+	var addr string
+	var filter atomic.Value
+	polls := atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/cluster":
+			_, _ = fmt.Fprintf(w, `{
+				"ready_nodes": [{"node_id": "searcher-0", "gossip_advertise_addr": %q}],
+				"chitchat_state_snapshot": {"node_state_snapshots": [
+					{"chitchat_id": {"node_id": "searcher-0", "gossip_advertise_addr": %q}, "node_state": {"key_values": {
+						"enabled_services": {"value": "searcher", "version": 1, "status": "Set"}
+					}}},
+					{"chitchat_id": {"node_id": "indexer-0", "gossip_advertise_addr": "127.0.0.1:1"}, "node_state": {"key_values": {
+						"enabled_services": {"value": "indexer", "version": 1, "status": "Set"}
+					}}}
+				]}
+			}`, addr, addr)
+		case DevLogLevelPath:
+			filter.Store(r.URL.Query().Get("filter"))
+		case DevPprofStartPath:
+			assert.Equal(t, "1", r.URL.Query().Get("duration"))
+		case DevPprofFlamegraph:
+			if polls.Add(1) < 2 {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = fmt.Fprint(w, `{"message": "profiling still running"}`)
+				return
+			}
+			_, _ = fmt.Fprint(w, "<svg/>")
+		case DevHeapProfilePath:
+			_, _ = fmt.Fprint(w, "heap_v2/524288")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	addr = strings.TrimPrefix(srv.URL, "http://")
+
+	// flamegraph polls answered with 500 must not open the circuit
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1})
+	c := New(WithEndpoint(srv.URL), WithCircuitBreaker(breaker), WithLogger(NewNopLogger()))
+	ctx := context.Background()
+
+	require.NoError(t, c.SetLogLevel(ctx, "info,quickwit_search=debug"))
+	assert.Equal(t, "info,quickwit_search=debug", filter.Load())
+
+	buf := &bytes.Buffer{}
+	// sub-second durations are rounded up
+	require.NoError(t, c.CPUProfile(ctx, buf, CPUProfileOptions{Duration: 200 * time.Millisecond}))
+	assert.Equal(t, "<svg/>", buf.String())
+	assert.Equal(t, int32(2), polls.Load())
+	assert.Equal(t, CircuitClosed, breaker.State(addr))
+
+	dir := t.TempDir()
+	profiles, err := c.ProfileNodes(ctx, dir, ProfileNodesOptions{Kind: ProfileHeap, Select: Searchers})
+	require.NoError(t, err)
+	require.Len(t, profiles, 1)
+	assert.Equal(t, "searcher-0", profiles[0].NodeID)
+	assert.Equal(t, srv.URL, profiles[0].Endpoint)
+	require.NoError(t, profiles[0].Err)
+
+	content, err := os.ReadFile(filepath.Join(dir, "searcher-0-heap.prof"))
+	require.NoError(t, err)
+	assert.Equal(t, "heap_v2/524288", string(content))
+
+	_, err = c.ProfileNodes(ctx, dir, ProfileNodesOptions{Kind: "goroutine"})
+	assert.Error(t, err)
+}
+
+func TestCPUProfileNodePool(t *testing.T) {
+	var paths [2][]string
+	mu := sync.Mutex{}
+	servers := []*httptest.Server{}
+	for i := range paths {
+		polls := 0
+		servers = append(servers, httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			paths[i] = append(paths[i], r.URL.Path)
+			if r.URL.Path == DevPprofFlamegraph {
+				if polls++; polls < 2 {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				_, _ = fmt.Fprint(w, "<svg/>")
+			}
+		})))
+		defer servers[i].Close()
+	}
+
+	pool, err := NewNodePool(NodePoolConfig{Seeds: []string{servers[0].URL, servers[1].URL}, DiscoveryInterval: -1, EjectAfter: 1, EjectFor: time.Minute})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	c := New(WithNodePool(pool), WithLogger(NewNopLogger()))
+
+	// the profiling starts and is polled on the same node, whose polls do not eject it
+	require.NoError(t, c.CPUProfile(context.Background(), &bytes.Buffer{}, CPUProfileOptions{Duration: time.Millisecond}))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.ElementsMatch(t, [][]string{{DevPprofStartPath, DevPprofFlamegraph, DevPprofFlamegraph}, nil}, paths[:])
+	for _, n := range pool.Nodes() {
+		assert.True(t, n.EjectedUntil.IsZero(), n.Endpoint)
+	}
+}
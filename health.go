@@ -0,0 +1,195 @@
+package quickwit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultWaitInitialBackoff = 250 * time.Millisecond
+	DefaultWaitMaxBackoff     = 5 * time.Second
+)
+
+var ErrNotReady = errors.New("quickwit: not ready")
+
+type WaitOptions struct {
+	// Wait between the first two checks, doubled after each check up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Gives up after this long, zero to rely on the context only
+	Timeout time.Duration
+	// Called after each check
+	OnCheck func(attempt int, ready bool, err error)
+}
+
+func (o WaitOptions) withDefaults() WaitOptions {
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = DefaultWaitInitialBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = DefaultWaitMaxBackoff
+	}
+	return o
+}
+
+// NodeHealth is the result of the probes of a cluster node
+type NodeHealth struct {
+	NodeID   string
+	Endpoint string
+	Live     bool
+	Ready    bool
+	// Error of the probes, when the node could not be reached
+	Err     error
+	Latency time.Duration
+}
+
+type nodeKey struct{}
+
+// TargetNode sends the calls made with this context to the given node endpoint (http://host:port),
+// bypassing the node pool
+func TargetNode(ctx context.Context, endpoint string) context.Context {
+	return context.WithValue(ctx, nodeKey{}, endpoint)
+}
+
+func targetNodeFromContext(ctx context.Context) (string, bool) {
+	endpoint, ok := ctx.Value(nodeKey{}).(string)
+	return endpoint, ok && endpoint != ""
+}
+
+// Livez tells whether the node is running, false when it answers 503
+func (c *client) Livez(ctx context.Context) (bool, error) {
+	return c.probe(ctx, OpLivez, "/health/livez")
+}
+
+// Readyz tells whether the node can take traffic, false when it answers 503
+func (c *client) Readyz(ctx context.Context) (bool, error) {
+	return c.probe(ctx, OpReadyz, "/health/readyz")
+}
+
+func (c *client) probe(ctx context.Context, op, path string) (bool, error) {
+	req, err := c.newRequest(ctx, Operation{Name: op}, http.MethodGet, path, nil)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := Request[bool](c.doer, c.log, req)
+
+	apiErr := &APIError{}
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusServiceUnavailable {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return *ok, nil
+}
+
+// WaitUntilReady checks readiness with an exponential backoff until Quickwit is ready.
+// The error matches ErrNotReady and wraps the last probe error or the context error.
+func (c *client) WaitUntilReady(ctx context.Context, opts WaitOptions) error {
+	opts = opts.withDefaults()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	backoff := opts.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		ready, err := c.Readyz(ctx)
+		if opts.OnCheck != nil {
+			opts.OnCheck(attempt, ready, err)
+		}
+		if ready {
+			return nil
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if err == nil {
+				err = ctx.Err()
+			}
+			return fmt.Errorf("%w after %d checks: %w", ErrNotReady, attempt, err)
+		case <-timer.C:
+		}
+
+		backoff = min(2*backoff, opts.MaxBackoff)
+	}
+}
+
+// NodesHealth probes the liveness and readiness of every node known by the cluster, concurrently
+func (c *client) NodesHealth(ctx context.Context) ([]NodeHealth, error) {
+	cluster, err := c.GetCluster(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme, restPort, err := c.nodeScheme()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []NodeHealth{}
+	for _, n := range cluster.LatestNodes() {
+		h := NodeHealth{NodeID: n.NodeID}
+		h.Endpoint, h.Err = nodeEndpoint(scheme, n.GossipAdvertiseAddr, restPort)
+		nodes = append(nodes, h)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := range nodes {
+		if nodes[i].Err != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(h *NodeHealth) {
+			defer wg.Done()
+
+			nodeCtx := TargetNode(ctx, h.Endpoint)
+			start := time.Now()
+			h.Live, h.Err = c.Livez(nodeCtx)
+			if h.Err == nil {
+				h.Ready, h.Err = c.Readyz(nodeCtx)
+			}
+			h.Latency = time.Since(start)
+		}(&nodes[i])
+	}
+	wg.Wait()
+
+	return nodes, nil
+}
+
+// nodeScheme returns the scheme and REST port of the cluster nodes, those of the node pool seeds when a pool is configured
+func (c *client) nodeScheme() (string, int, error) {
+	if c.pool != nil {
+		return c.pool.scheme, c.pool.cfg.RESTPort, nil
+	}
+
+	u, err := url.Parse(c.endpoint)
+	if err != nil {
+		return "", 0, err
+	}
+	return u.Scheme, 0, nil
+}
+
+// nodeEndpoint returns the REST endpoint of a node from its gossip address, the gossip port being the REST port by default
+func nodeEndpoint(scheme, gossipAddr string, restPort int) (string, error) {
+	host, port, err := net.SplitHostPort(gossipAddr)
+	if err != nil {
+		return "", fmt.Errorf("invalid node address %q: %w", gossipAddr, err)
+	}
+	if restPort > 0 {
+		port = fmt.Sprint(restPort)
+	}
+	return scheme + "://" + net.JoinHostPort(host, port), nil
+}
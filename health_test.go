@@ -0,0 +1,123 @@
+package quickwit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitUntilReady(t *testing.T) {
+	checks := atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health/livez" {
+			_, _ = fmt.Fprint(w, "true")
+			return
+		}
+		if checks.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = fmt.Fprint(w, "false")
+			return
+		}
+		_, _ = fmt.Fprint(w, "true")
+	}))
+	defer srv.Close()
+
+	// readyz 503s while the node starts must not open the circuit
+	transitions := 0
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{
+		FailureThreshold: 1,
+		OnStateChange:    func(string, CircuitState, CircuitState) { transitions++ },
+	})
+	c := New(WithEndpoint(srv.URL), WithCircuitBreaker(breaker), WithLogger(NewNopLogger()))
+
+	live, err := c.Livez(context.Background())
+	require.NoError(t, err)
+	assert.True(t, live)
+
+	ready, err := c.Readyz(context.Background())
+	require.NoError(t, err)
+	assert.False(t, ready)
+
+	err = c.WaitUntilReady(context.Background(), WaitOptions{InitialBackoff: time.Millisecond})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), checks.Load())
+	assert.Zero(t, transitions)
+
+	down := New(WithEndpoint("http://127.0.0.1:1"), WithLogger(NewNopLogger()))
+	err = down.WaitUntilReady(context.Background(), WaitOptions{InitialBackoff: time.Millisecond, Timeout: 20 * time.Millisecond})
+	assert.ErrorIs(t, err, ErrNotReady)
+}
+
+func TestNodesHealth(t *testing.T) {
+	var addr string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/cluster":
+			_, _ = fmt.Fprintf(w, `{
+				"ready_nodes": [{"node_id": "searcher-0", "gossip_advertise_addr": %q}],
+				"chitchat_state_snapshot": {"node_state_snapshots": [
+					{"chitchat_id": {"node_id": "searcher-0", "gossip_advertise_addr": %q}},
+					{"chitchat_id": {"node_id": "indexer-0", "gossip_advertise_addr": "127.0.0.1:1"}}
+				]}
+			}`, addr, addr)
+		case "/health/livez":
+			_, _ = fmt.Fprint(w, "true")
+		case "/health/readyz":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = fmt.Fprint(w, "false")
+		}
+	}))
+	defer srv.Close()
+	addr = strings.TrimPrefix(srv.URL, "http://")
+
+	c := New(WithEndpoint(srv.URL), WithLogger(NewNopLogger()))
+
+	nodes, err := c.NodesHealth(context.Background())
+	require.NoError(t, err)
+	require.Len(t, nodes, 2)
+
+	// sorted by node ID
+	assert.Equal(t, "indexer-0", nodes[0].NodeID)
+	assert.Error(t, nodes[0].Err)
+
+	assert.Equal(t, "searcher-0", nodes[1].NodeID)
+	assert.Equal(t, srv.URL, nodes[1].Endpoint)
+	assert.True(t, nodes[1].Live)
+	assert.False(t, nodes[1].Ready)
+	assert.NoError(t, nodes[1].Err)
+}
+
+func TestNodesHealthNodePool(t *testing.T) {
+	urls := []string{}
+	mu := sync.Mutex{}
+	doer := doerFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		urls = append(urls, req.URL.Scheme+"://"+req.URL.Host+req.URL.Path)
+		if req.URL.Path == "/api/v1/cluster" {
+			return jsonResponse(http.StatusOK, `{"ready_nodes": [{"node_id": "searcher-0", "gossip_advertise_addr": "10.0.0.1:7280"}]}`), nil
+		}
+		return jsonResponse(http.StatusOK, `true`), nil
+	})
+
+	pool, err := NewNodePool(NodePoolConfig{Seeds: []string{"https://quickwit:7280"}, DiscoveryInterval: -1})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	// the client endpoint is left to its http default, nodes are probed with the scheme of the seeds
+	c := New(WithNodePool(pool), WithDoer(doer), WithLogger(NewNopLogger()))
+	nodes, err := c.NodesHealth(context.Background())
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "https://10.0.0.1:7280", nodes[0].Endpoint)
+	assert.Equal(t, []string{"https://quickwit:7280/api/v1/cluster", "https://10.0.0.1:7280/health/livez", "https://10.0.0.1:7280/health/readyz"}, urls)
+}
@@ -0,0 +1,308 @@
+package quickwit
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultHedgeMinSamples = 20
+	DefaultHedgeMaxHedges  = 1
+	hedgeLatencyWindow     = 1000
+)
+
+// HedgingPolicy decides when a duplicate of a slow read-only request is sent.
+// Hedges are only useful with several nodes, see WithNodePool.
+type HedgingPolicy struct {
+	// Wait before sending a hedge, used until enough latencies are known when Percentile is set
+	Delay time.Duration
+	// Send a hedge once the request is slower than this percentile of the recent latencies of the operation, e.g. 0.95
+	Percentile float64
+	// Latencies needed before the percentile is used
+	MinSamples int
+	// Duplicates sent at most for a request
+	MaxHedges int
+	// Operations eligible to hedging, defaults to OpSearch and OpDescribeIndex. Only GET requests are hedged.
+	Operations []string
+}
+
+func (p HedgingPolicy) withDefaults() HedgingPolicy {
+	if p.MinSamples <= 0 {
+		p.MinSamples = DefaultHedgeMinSamples
+	}
+	if p.MaxHedges <= 0 {
+		p.MaxHedges = DefaultHedgeMaxHedges
+	}
+	if len(p.Operations) == 0 {
+		p.Operations = []string{OpSearch, OpDescribeIndex}
+	}
+	return p
+}
+
+type HedgeStats struct {
+	// Requests eligible to hedging
+	Requests uint64
+	// Requests for which at least one hedge was sent
+	Hedged uint64
+	// Hedges sent
+	Hedges uint64
+	// Requests answered first by a hedge
+	HedgeWins uint64
+}
+
+// HedgeRate returns the fraction of requests which were hedged
+func (s HedgeStats) HedgeRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Hedged) / float64(s.Requests)
+}
+
+// Hedger sends duplicates of slow read-only requests and keeps the first answer, the others are cancelled
+type Hedger struct {
+	policy HedgingPolicy
+
+	mu        sync.Mutex
+	latencies map[string]*latencyWindow
+	stats     map[string]*HedgeStats
+}
+
+func NewHedger(policy HedgingPolicy) *Hedger {
+	return &Hedger{
+		policy:    policy.withDefaults(),
+		latencies: map[string]*latencyWindow{},
+		stats:     map[string]*HedgeStats{},
+	}
+}
+
+// Stats returns the hedging metrics of each operation seen so far
+func (h *Hedger) Stats() map[string]HedgeStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stats := make(map[string]HedgeStats, len(h.stats))
+	for op, s := range h.stats {
+		stats[op] = *s
+	}
+	return stats
+}
+
+type hedgeResult struct {
+	res      *http.Response
+	err      error
+	attempt  int
+	duration time.Duration
+}
+
+// Middleware hedges the eligible requests, the node pool sends each hedge to a node not used yet by the request
+func (h *Hedger) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			op, _ := OperationFromContext(req.Context())
+			if req.Method != http.MethodGet || !slices.Contains(h.policy.Operations, op.Name) {
+				return next.RoundTrip(req)
+			}
+
+			delay, ok := h.delay(op.Name)
+			if !ok {
+				start := time.Now()
+				res, err := next.RoundTrip(req)
+
+				var latency time.Duration
+				if err == nil && res.StatusCode < 500 {
+					latency = time.Since(start)
+				}
+				h.record(op.Name, nil, latency)
+
+				return res, err
+			}
+
+			return h.hedge(next, req, op.Name, delay)
+		})
+	}
+}
+
+func (h *Hedger) hedge(next http.RoundTripper, req *http.Request, op string, delay time.Duration) (*http.Response, error) {
+	ctx := context.WithValue(req.Context(), hedgeGroupKey{}, &hedgeGroup{})
+	results := make(chan hedgeResult, 1+h.policy.MaxHedges)
+	cancels := []context.CancelFunc{}
+
+	launch := func() {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		attempt := len(cancels)
+		cancels = append(cancels, cancel)
+
+		go func() {
+			start := time.Now()
+			res, err := next.RoundTrip(req.Clone(attemptCtx))
+			results <- hedgeResult{res: res, err: err, attempt: attempt, duration: time.Since(start)}
+		}()
+	}
+
+	launch()
+	pending := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var last hedgeResult
+	for {
+		select {
+		case <-timer.C:
+			if len(cancels) <= h.policy.MaxHedges {
+				launch()
+				pending++
+				timer.Reset(delay)
+			}
+			continue
+		case last = <-results:
+			pending--
+		}
+
+		won := last.err == nil && last.res.StatusCode < 500
+		if !won && pending > 0 {
+			if last.res != nil {
+				_ = last.res.Body.Close()
+			}
+			cancels[last.attempt]()
+			continue
+		}
+
+		// cancel the other attempts, the winner is cancelled once its body is closed
+		for i, cancel := range cancels {
+			if i != last.attempt {
+				cancel()
+			}
+		}
+		go drainHedges(results, pending)
+
+		hedges := uint64(len(cancels) - 1)
+		var latency time.Duration
+		if won {
+			latency = last.duration
+		}
+		h.record(op, func(s *HedgeStats) {
+			s.Hedges += hedges
+			if hedges > 0 {
+				s.Hedged++
+			}
+			if won && last.attempt > 0 {
+				s.HedgeWins++
+			}
+		}, latency)
+
+		if last.err != nil {
+			cancels[last.attempt]()
+			return nil, last.err
+		}
+		last.res.Body = &releaseOnClose{ReadCloser: last.res.Body, release: cancels[last.attempt]}
+		return last.res, nil
+	}
+}
+
+// drainHedges closes the responses of the cancelled attempts
+func drainHedges(results <-chan hedgeResult, pending int) {
+	for range pending {
+		if r := <-results; r.res != nil {
+			_ = r.res.Body.Close()
+		}
+	}
+}
+
+// delay returns the wait before hedging an operation, false when it is not hedged yet
+func (h *Hedger) delay(op string) (time.Duration, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.policy.Percentile > 0 {
+		if w := h.latencies[op]; w != nil && len(w.samples) >= h.policy.MinSamples {
+			return w.percentile(h.policy.Percentile), true
+		}
+	}
+	return h.policy.Delay, h.policy.Delay > 0
+}
+
+// record updates the operation stats, latency is the duration of the successful attempt or zero
+func (h *Hedger) record(op string, update func(*HedgeStats), latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.stats[op]
+	if !ok {
+		s = &HedgeStats{}
+		h.stats[op] = s
+	}
+	s.Requests++
+	if update != nil {
+		update(s)
+	}
+
+	if latency > 0 && h.policy.Percentile > 0 {
+		w, ok := h.latencies[op]
+		if !ok {
+			w = &latencyWindow{}
+			h.latencies[op] = w
+		}
+		w.add(latency)
+	}
+}
+
+// latencyWindow keeps the most recent latencies of an operation
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+}
+
+func (w *latencyWindow) add(d time.Duration) {
+	if len(w.samples) < hedgeLatencyWindow {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % hedgeLatencyWindow
+}
+
+func (w *latencyWindow) percentile(p float64) time.Duration {
+	sorted := slices.Clone(w.samples)
+	slices.Sort(sorted)
+
+	i := int(p * float64(len(sorted)))
+	return sorted[min(i, len(sorted)-1)]
+}
+
+// hedgeGroup tracks the nodes used by the attempts of a hedged request
+type hedgeGroup struct {
+	mu    sync.Mutex
+	nodes map[string]bool
+}
+
+type hedgeGroupKey struct{}
+
+func hedgeGroupFromContext(ctx context.Context) *hedgeGroup {
+	g, _ := ctx.Value(hedgeGroupKey{}).(*hedgeGroup)
+	return g
+}
+
+func (g *hedgeGroup) used(endpoint string) bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.nodes[endpoint]
+}
+
+func (g *hedgeGroup) use(endpoint string) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.nodes == nil {
+		g.nodes = map[string]bool{}
+	}
+	g.nodes[endpoint] = true
+}
@@ -0,0 +1,59 @@
+package quickwit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedging(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"num_hits": 1}`)
+	}))
+	defer slow.Close()
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"num_hits": 2}`)
+	}))
+	defer fast.Close()
+
+	pool, err := NewNodePool(NodePoolConfig{Seeds: []string{slow.URL, fast.URL}, DiscoveryInterval: -1})
+	require.NoError(t, err)
+	hedger := NewHedger(HedgingPolicy{Delay: 20 * time.Millisecond})
+
+	c := New(WithNodePool(pool), WithHedging(hedger), WithLogger(NewNopLogger()))
+
+	start := time.Now()
+	for range 4 {
+		res, err := c.Search(context.Background(), "logs", "*")
+		require.NoError(t, err)
+		assert.Equal(t, 2, res.NumHits)
+	}
+	assert.Less(t, time.Since(start), time.Second)
+
+	stats := hedger.Stats()[OpSearch]
+	assert.Equal(t, uint64(4), stats.Requests)
+	assert.Positive(t, stats.HedgeWins)
+	// the slow node never answers first
+	assert.Equal(t, stats.Hedged, stats.HedgeWins)
+
+	assert.Eventually(t, func() bool {
+		for _, n := range pool.Nodes() {
+			if n.InFlight != 0 {
+				return false
+			}
+		}
+		return true
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,246 @@
+package quickwit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// ChangeApplicability tells how a config change reaches an existing index
+type ChangeApplicability string
+
+const (
+	// Applied by UpdateIndex to the whole index
+	ChangeLive ChangeApplicability = "live"
+	// Applied by UpdateIndex to the documents indexed afterwards, existing splits keep their doc mapping
+	ChangeNewSplits ChangeApplicability = "new_splits"
+	// Cannot be updated, the index must be recreated
+	ChangeRecreate ChangeApplicability = "recreate"
+)
+
+// IndexConfigChange is a field changed between two index configs, Path uses the JSON field names
+// and field mappings by name, e.g. "doc_mapping.field_mappings[body].tokenizer"
+type IndexConfigChange struct {
+	Path          string
+	From          any
+	To            any
+	Applicability ChangeApplicability
+	// Searches on existing splits may break or return partial results, e.g. a field type change
+	Breaking bool
+}
+
+func (c IndexConfigChange) String() string {
+	s := fmt.Sprintf("%s: %v -> %v (%s)", c.Path, c.From, c.To, c.Applicability)
+	if c.Breaking {
+		s += " breaking"
+	}
+	return s
+}
+
+type IndexConfigDiff []IndexConfigChange
+
+// Live tells whether every change can be applied live by UpdateIndex
+func (d IndexConfigDiff) Live() bool {
+	for _, c := range d {
+		if c.Applicability != ChangeLive {
+			return false
+		}
+	}
+	return true
+}
+
+// RequiresRecreate tells whether some change cannot be applied by UpdateIndex
+func (d IndexConfigDiff) RequiresRecreate() bool {
+	for _, c := range d {
+		if c.Applicability == ChangeRecreate {
+			return true
+		}
+	}
+	return false
+}
+
+func (d IndexConfigDiff) String() string {
+	lines := make([]string, len(d))
+	for i, c := range d {
+		lines[i] = c.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DiffIndexConfig lists the changes from a to b, the config version is ignored
+func DiffIndexConfig(a, b IndexConfig) IndexConfigDiff {
+	diff := IndexConfigDiff{}
+	add := func(path string, from, to any, applicability ChangeApplicability, breaking bool) {
+		diff = append(diff, IndexConfigChange{Path: path, From: from, To: to, Applicability: applicability, Breaking: breaking})
+	}
+
+	if a.ID != b.ID {
+		add("index_id", a.ID, b.ID, ChangeRecreate, false)
+	}
+	// the URI is set by the cluster when empty
+	if a.URI != b.URI && a.URI != "" && b.URI != "" {
+		add("index_uri", a.URI, b.URI, ChangeRecreate, false)
+	}
+
+	diffFields("indexing_settings", a.IndexingSettings, b.IndexingSettings, func(path string, from, to any) {
+		add(path, from, to, ChangeLive, false)
+	})
+	diffFields("search_settings", a.SearchSettings, b.SearchSettings, func(path string, from, to any) {
+		add(path, from, to, ChangeLive, false)
+	})
+	diffFields("retention", a.Retention, b.Retention, func(path string, from, to any) {
+		add(path, from, to, ChangeLive, false)
+	})
+
+	diffDocMapping(a.DocMapping, b.DocMapping, add)
+
+	return diff
+}
+
+func diffDocMapping(a, b DocMapping, add func(path string, from, to any, applicability ChangeApplicability, breaking bool)) {
+	// pruning on existing splits relies on the timestamp field
+	if a.TimestampField != b.TimestampField {
+		add("doc_mapping.timestamp_field", a.TimestampField, b.TimestampField, ChangeRecreate, false)
+	}
+	a.TimestampField, b.TimestampField = "", ""
+
+	fieldsA, fieldsB := map[string]FieldMapping{}, map[string]FieldMapping{}
+	for _, f := range a.FieldMappings {
+		fieldsA[f.Name] = f
+	}
+	for _, f := range b.FieldMappings {
+		fieldsB[f.Name] = f
+	}
+
+	for _, f := range a.FieldMappings {
+		path := fmt.Sprintf("doc_mapping.field_mappings[%s]", f.Name)
+		to, ok := fieldsB[f.Name]
+		if !ok {
+			add(path, f.Type, nil, ChangeNewSplits, true)
+			continue
+		}
+
+		diffFields(path, f, to, func(path string, from, to any) {
+			// existing splits keep the old type or indexing, queries relying on the new one may fail on them
+			breaking := strings.HasSuffix(path, ".type") || (strings.HasSuffix(path, ".indexed") && to == true)
+			add(path, from, to, ChangeNewSplits, breaking)
+		})
+	}
+	for _, f := range b.FieldMappings {
+		if _, ok := fieldsA[f.Name]; !ok {
+			add(fmt.Sprintf("doc_mapping.field_mappings[%s]", f.Name), nil, f.Type, ChangeNewSplits, false)
+		}
+	}
+
+	a.FieldMappings, b.FieldMappings = nil, nil
+	diffFields("doc_mapping", a, b, func(path string, from, to any) {
+		add(path, from, to, ChangeNewSplits, false)
+	})
+}
+
+// diffFields walks structs by JSON field name and reports the leaves which differ
+func diffFields(path string, a, b any, report func(path string, from, to any)) {
+	if reflect.DeepEqual(a, b) {
+		return
+	}
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() || va.Type() != vb.Type() {
+		report(path, a, b)
+		return
+	}
+
+	if va.Kind() == reflect.Pointer {
+		if va.IsNil() || vb.IsNil() {
+			if va.IsNil() != vb.IsNil() {
+				report(path, nilOrValue(va), nilOrValue(vb))
+			}
+			return
+		}
+		va, vb = va.Elem(), vb.Elem()
+	}
+
+	if va.Kind() != reflect.Struct {
+		report(path, va.Interface(), vb.Interface())
+		return
+	}
+
+	for i := 0; i < va.NumField(); i++ {
+		name, _, _ := strings.Cut(va.Type().Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		diffFields(path+"."+name, va.Field(i).Interface(), vb.Field(i).Interface(), report)
+	}
+}
+
+func nilOrValue(v reflect.Value) any {
+	if v.IsNil() {
+		return nil
+	}
+	return v.Elem().Interface()
+}
+
+// UpdateIndex replaces the config of an existing index, use DiffIndexConfig to preview the changes.
+// It needs Quickwit 0.9 or later and returns an *UnsupportedError otherwise.
+// An index cannot be renamed: cfg.ID must be empty or match indexID.
+func (c *client) UpdateIndex(ctx context.Context, indexID string, cfg IndexConfig) (*Index, error) {
+	if cfg.ID != "" && cfg.ID != indexID {
+		return nil, fmt.Errorf("cannot update index %s with the config of index %s", indexID, cfg.ID)
+	}
+
+	caps, err := c.updateCapabilities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Version == "" {
+		cfg.Version = caps.IndexConfigVersion
+	}
+	cfg.ID = indexID
+
+	return c.putIndexConfig(ctx, indexID, cfg)
+}
+
+// updateIndexDocument is UpdateIndex for a raw index_config document, which keeps the settings IndexConfig does not model
+func (c *client) updateIndexDocument(ctx context.Context, indexID string, doc map[string]any) (*Index, error) {
+	caps, err := c.updateCapabilities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if version, _ := doc["version"].(string); version == "" {
+		doc["version"] = caps.IndexConfigVersion
+	}
+	doc["index_id"] = indexID
+
+	return c.putIndexConfig(ctx, indexID, doc)
+}
+
+func (c *client) updateCapabilities(ctx context.Context) (*Capabilities, error) {
+	caps, err := c.Capabilities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := caps.require("UpdateIndex", 0, 9); err != nil {
+		return nil, err
+	}
+	return caps, nil
+}
+
+func (c *client) putIndexConfig(ctx context.Context, indexID string, cfg any) (*Index, error) {
+	req, err := c.newRequest(
+		ctx,
+		Operation{Name: OpUpdateIndex, IndexID: indexID},
+		http.MethodPut,
+		fmt.Sprintf("/api/v1/indexes/%s", indexID),
+		MustMarshall(cfg),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return Request[Index](c.doer, c.log, req)
+}
@@ -0,0 +1,102 @@
+package quickwit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffIndexConfig(t *testing.T) {
+	a := IndexConfig{
+		Version: "0.8",
+		ID:      "logs",
+		DocMapping: DocMapping{
+			Mode:           "dynamic",
+			TimestampField: "timestamp",
+			FieldMappings: []FieldMapping{
+				{Name: "timestamp", Type: "datetime", Fast: true},
+				{Name: "body", Type: "text", Tokenizer: "default"},
+				{Name: "level", Type: "text"},
+			},
+		},
+		SearchSettings: SearchSettings{DefaultSearchFields: []string{"body"}},
+	}
+	assert.Empty(t, DiffIndexConfig(a, a))
+
+	b := a
+	b.Version = "0.9"
+	b.URI = "s3://bucket/logs"
+	b.Retention = &IndexRetention{Period: "30 days", Schedule: "daily"}
+	b.SearchSettings = SearchSettings{DefaultSearchFields: []string{"body", "level"}}
+	b.DocMapping.FieldMappings = []FieldMapping{
+		{Name: "timestamp", Type: "datetime", Fast: true},
+		{Name: "body", Type: "text", Tokenizer: "raw"},
+		{Name: "level", Type: "u64"},
+		{Name: "service", Type: "text", Fast: true},
+	}
+
+	diff := DiffIndexConfig(a, b)
+	assert.Equal(t, IndexConfigDiff{
+		{Path: "search_settings.default_search_fields", From: []string{"body"}, To: []string{"body", "level"}, Applicability: ChangeLive},
+		{Path: "retention", From: nil, To: IndexRetention{Period: "30 days", Schedule: "daily"}, Applicability: ChangeLive},
+		{Path: "doc_mapping.field_mappings[body].tokenizer", From: "default", To: "raw", Applicability: ChangeNewSplits},
+		{Path: "doc_mapping.field_mappings[level].type", From: "text", To: "u64", Applicability: ChangeNewSplits, Breaking: true},
+		{Path: "doc_mapping.field_mappings[service]", From: nil, To: "text", Applicability: ChangeNewSplits},
+	}, diff)
+	assert.False(t, diff.Live())
+	assert.False(t, diff.RequiresRecreate())
+
+	c := b
+	c.DocMapping.TimestampField = "ts"
+	c.DocMapping.FieldMappings = b.DocMapping.FieldMappings[1:]
+	diff = DiffIndexConfig(b, c)
+	assert.True(t, diff.RequiresRecreate())
+	assert.Equal(t, "doc_mapping.timestamp_field: timestamp -> ts (recreate)\ndoc_mapping.field_mappings[timestamp]: datetime -> <nil> (new_splits) breaking", diff.String())
+
+	d := b
+	d.Retention = &IndexRetention{Period: "7 days", Schedule: "daily"}
+	assert.True(t, DiffIndexConfig(b, d).Live())
+}
+
+func TestUpdateIndex(t *testing.T) {
+	version := "0.8.2"
+	var updated IndexConfig
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v1/version":
+			_, _ = fmt.Fprintf(w, `{"build": {"version": %q}}`, version)
+		case r.Method == http.MethodPut && r.URL.Path == "/api/v1/indexes/logs":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&updated))
+			_ = json.NewEncoder(w).Encode(map[string]any{"index_config": updated})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := IndexConfig{Retention: &IndexRetention{Period: "30 days", Schedule: "daily"}}
+
+	old := New(WithEndpoint(srv.URL), WithLogger(NewNopLogger()))
+	_, err := old.UpdateIndex(context.Background(), "logs", cfg)
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	version = "0.9.0"
+	c := New(WithEndpoint(srv.URL), WithLogger(NewNopLogger()))
+	index, err := c.UpdateIndex(context.Background(), "logs", cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "logs", updated.ID)
+	assert.Equal(t, "0.9", updated.Version)
+	assert.Equal(t, cfg.Retention, index.Config.Retention)
+
+	updated = IndexConfig{}
+	cfg.ID = "other"
+	_, err = c.UpdateIndex(context.Background(), "logs", cfg)
+	assert.ErrorContains(t, err, "cannot update index logs with the config of index other")
+	assert.Empty(t, updated.ID, "nothing is sent")
+}
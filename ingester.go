@@ -0,0 +1,295 @@
+package quickwit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrIngesterClosed = errors.New("quickwit: ingester is closed")
+	ErrIngesterFull   = errors.New("quickwit: ingester buffer is full, document dropped")
+)
+
+// DropPolicy tells the ingester what to do when its buffer is full
+type DropPolicy int
+
+const (
+	// DropNewest discards the document being added
+	DropNewest DropPolicy = iota
+	// DropOldest evicts the oldest buffered documents to make room
+	DropOldest
+)
+
+const (
+	DefaultIngestBatchSize        = 1000
+	DefaultIngestBatchBytes       = 5 << 20
+	DefaultIngestFlushInterval    = time.Second
+	DefaultIngestMaxBufferedDocs  = 10_000
+	DefaultIngestMaxBufferedBytes = 50 << 20
+	DefaultIngestRequestTimeout   = 10 * time.Second
+)
+
+type IngesterConfig struct {
+	// Maximum number of documents sent in a single ingest request
+	BatchSize int
+	// Maximum payload size of a single ingest request
+	BatchBytes int
+	// Buffered documents are sent at least this often
+	FlushInterval time.Duration
+	// Bounds of the in-memory buffer, see DropPolicy
+	MaxBufferedDocs  int
+	MaxBufferedBytes int
+	DropPolicy       DropPolicy
+	// Timeout applied to each ingest request
+	RequestTimeout time.Duration
+	// Called from the background goroutine when a batch cannot be sent, the batch is dropped
+	OnError func(err error, docs int)
+}
+
+func (cfg IngesterConfig) withDefaults() IngesterConfig {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultIngestBatchSize
+	}
+	if cfg.BatchBytes <= 0 {
+		cfg.BatchBytes = DefaultIngestBatchBytes
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultIngestFlushInterval
+	}
+	if cfg.MaxBufferedDocs <= 0 {
+		cfg.MaxBufferedDocs = DefaultIngestMaxBufferedDocs
+	}
+	if cfg.MaxBufferedBytes <= 0 {
+		cfg.MaxBufferedBytes = DefaultIngestMaxBufferedBytes
+	}
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = DefaultIngestRequestTimeout
+	}
+	return cfg
+}
+
+type IngesterStats struct {
+	Accepted uint64 // documents buffered
+	Sent     uint64 // documents acknowledged by Quickwit
+	Dropped  uint64 // documents discarded because the buffer was full
+	Failed   uint64 // documents lost in failed ingest requests
+}
+
+// Ingester buffers documents in memory and sends them asynchronously in batches.
+// Add never blocks: once the buffer is full, documents are dropped according to the DropPolicy.
+type Ingester struct {
+	client  Client
+	indexID string
+	cfg     IngesterConfig
+
+	mu     sync.Mutex
+	queue  [][]byte
+	size   int
+	closed bool
+
+	notify   chan struct{}
+	flushReq chan chan error
+	stop     chan struct{}
+	done     chan struct{}
+	// error of the final flush, set before done is closed
+	closeErr error
+
+	accepted atomic.Uint64
+	sent     atomic.Uint64
+	dropped  atomic.Uint64
+	failed   atomic.Uint64
+}
+
+func NewIngester(c Client, indexID string, cfg IngesterConfig) *Ingester {
+	i := &Ingester{
+		client:   c,
+		indexID:  indexID,
+		cfg:      cfg.withDefaults(),
+		notify:   make(chan struct{}, 1),
+		flushReq: make(chan chan error),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go i.run()
+
+	return i
+}
+
+// Add JSON encodes the document and buffers it
+func (i *Ingester) Add(doc any) error {
+	line, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.closed {
+		return ErrIngesterClosed
+	}
+
+	if len(line) > i.cfg.MaxBufferedBytes || len(line) > i.cfg.BatchBytes {
+		i.dropped.Add(1)
+		return ErrIngesterFull
+	}
+
+	for len(i.queue) >= i.cfg.MaxBufferedDocs || i.size+len(line) > i.cfg.MaxBufferedBytes {
+		if i.cfg.DropPolicy != DropOldest {
+			i.dropped.Add(1)
+			return ErrIngesterFull
+		}
+		i.size -= len(i.queue[0])
+		i.queue[0] = nil
+		i.queue = i.queue[1:]
+		i.dropped.Add(1)
+	}
+
+	i.queue = append(i.queue, line)
+	i.size += len(line)
+	i.accepted.Add(1)
+
+	if len(i.queue) >= i.cfg.BatchSize || i.size >= i.cfg.BatchBytes {
+		select {
+		case i.notify <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Flush sends every buffered document and waits for the requests to complete
+func (i *Ingester) Flush(ctx context.Context) error {
+	res := make(chan error, 1)
+
+	select {
+	case i.flushReq <- res:
+	case <-i.done:
+		return ErrIngesterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-res:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting documents, flushes the buffer and stops the background goroutine.
+// It returns the error of the final flush.
+func (i *Ingester) Close(ctx context.Context) error {
+	i.mu.Lock()
+	if i.closed {
+		i.mu.Unlock()
+		return nil
+	}
+	i.closed = true
+	i.mu.Unlock()
+
+	close(i.stop)
+
+	select {
+	case <-i.done:
+		return i.closeErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (i *Ingester) Stats() IngesterStats {
+	return IngesterStats{
+		Accepted: i.accepted.Load(),
+		Sent:     i.sent.Load(),
+		Dropped:  i.dropped.Load(),
+		Failed:   i.failed.Load(),
+	}
+}
+
+func (i *Ingester) run() {
+	defer close(i.done)
+
+	ticker := time.NewTicker(i.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-i.notify:
+			i.send(false)
+		case <-ticker.C:
+			i.send(true)
+		case res := <-i.flushReq:
+			res <- i.send(true)
+		case <-i.stop:
+			i.closeErr = i.send(true)
+			return
+		}
+	}
+}
+
+// send drains the buffer batch by batch, partial batches are only sent when all is set
+func (i *Ingester) send(all bool) error {
+	var errs []error
+
+	for {
+		batch, n := i.nextBatch(all)
+		if n == 0 {
+			return errors.Join(errs...)
+		}
+
+		if err := i.ingest(batch, n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+}
+
+func (i *Ingester) nextBatch(all bool) (*bytes.Buffer, int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if len(i.queue) == 0 {
+		return nil, 0
+	}
+	if !all && len(i.queue) < i.cfg.BatchSize && i.size < i.cfg.BatchBytes {
+		return nil, 0
+	}
+
+	buf := &bytes.Buffer{}
+	n := 0
+	for n < len(i.queue) && n < i.cfg.BatchSize && buf.Len()+len(i.queue[n]) <= i.cfg.BatchBytes {
+		buf.Write(i.queue[n])
+		i.queue[n] = nil
+		n++
+	}
+
+	i.queue = i.queue[n:]
+	i.size -= buf.Len()
+
+	return buf, n
+}
+
+func (i *Ingester) ingest(batch *bytes.Buffer, n int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), i.cfg.RequestTimeout)
+	defer cancel()
+
+	if _, err := i.client.Ingest(ctx, i.indexID, batch); err != nil {
+		i.failed.Add(uint64(n))
+		if i.cfg.OnError != nil {
+			i.cfg.OnError(err, n)
+		}
+		return err
+	}
+
+	i.sent.Add(uint64(n))
+	return nil
+}
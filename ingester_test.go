@@ -0,0 +1,100 @@
+package quickwit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIngestClient records the ingested batches
+type fakeIngestClient struct {
+	Client
+
+	mu      sync.Mutex
+	batches [][]string
+	err     error
+}
+
+func (c *fakeIngestClient) Ingest(_ context.Context, _ string, docs io.Reader) (*IngestResponse, error) {
+	b, _ := io.ReadAll(docs)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	c.batches = append(c.batches, strings.Split(strings.TrimSuffix(string(b), "\n"), "\n"))
+	return &IngestResponse{}, nil
+}
+
+func (c *fakeIngestClient) Batches() [][]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.batches
+}
+
+func TestIngester(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Batch Bounds", func(t *testing.T) {
+		c := &fakeIngestClient{}
+		i := NewIngester(c, "logs", IngesterConfig{BatchSize: 2, BatchBytes: 8, FlushInterval: time.Hour})
+
+		// `"a"\n` is 4 bytes, a batch holds 2 documents and 8 bytes
+		for _, doc := range []string{"a", "b", "c", "dd", "e"} {
+			require.NoError(t, i.Add(doc))
+		}
+		require.NoError(t, i.Flush(ctx))
+
+		assert.Equal(t, [][]string{{`"a"`, `"b"`}, {`"c"`}, {`"dd"`}, {`"e"`}}, c.Batches())
+		assert.Equal(t, IngesterStats{Accepted: 5, Sent: 5}, i.Stats())
+
+		assert.ErrorIs(t, i.Add("larger than a batch"), ErrIngesterFull)
+		require.NoError(t, i.Close(ctx))
+		assert.ErrorIs(t, i.Add("a"), ErrIngesterClosed)
+	})
+
+	t.Run("Drop Newest", func(t *testing.T) {
+		c := &fakeIngestClient{}
+		i := NewIngester(c, "logs", IngesterConfig{MaxBufferedDocs: 2, FlushInterval: time.Hour})
+
+		require.NoError(t, i.Add("a"))
+		require.NoError(t, i.Add("b"))
+		assert.ErrorIs(t, i.Add("c"), ErrIngesterFull)
+		require.NoError(t, i.Close(ctx))
+
+		assert.Equal(t, [][]string{{`"a"`, `"b"`}}, c.Batches())
+		assert.Equal(t, IngesterStats{Accepted: 2, Sent: 2, Dropped: 1}, i.Stats())
+	})
+
+	t.Run("Drop Oldest", func(t *testing.T) {
+		c := &fakeIngestClient{}
+		i := NewIngester(c, "logs", IngesterConfig{MaxBufferedBytes: 8, DropPolicy: DropOldest, FlushInterval: time.Hour})
+
+		require.NoError(t, i.Add("a"))
+		require.NoError(t, i.Add("b"))
+		require.NoError(t, i.Add("c"))
+		require.NoError(t, i.Close(ctx))
+
+		assert.Equal(t, [][]string{{`"b"`, `"c"`}}, c.Batches())
+		assert.Equal(t, IngesterStats{Accepted: 3, Sent: 2, Dropped: 1}, i.Stats())
+	})
+
+	t.Run("Close Returns Flush Error", func(t *testing.T) {
+		c := &fakeIngestClient{err: errors.New("unavailable")}
+		failed := 0
+		i := NewIngester(c, "logs", IngesterConfig{FlushInterval: time.Hour, OnError: func(_ error, docs int) { failed += docs }})
+
+		require.NoError(t, i.Add("a"))
+		assert.EqualError(t, i.Close(ctx), "unavailable")
+		assert.Equal(t, 1, failed)
+		assert.Equal(t, IngesterStats{Accepted: 1, Failed: 1}, i.Stats())
+	})
+}
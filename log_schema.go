@@ -0,0 +1,161 @@
+package quickwit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// LogSchema names the document fields log records are mapped to.
+// Empty fields fall back to the DefaultLogSchema names.
+type LogSchema struct {
+	TimestampField  string
+	LevelField      string
+	MessageField    string
+	AttributesField string
+	SourceField     string
+	// Put attributes at the root of the document instead of under AttributesField
+	FlattenAttributes bool
+}
+
+func DefaultLogSchema() LogSchema {
+	return LogSchema{
+		TimestampField:  "timestamp",
+		LevelField:      "level",
+		MessageField:    "message",
+		AttributesField: "fields",
+		SourceField:     "caller",
+	}
+}
+
+func (s LogSchema) withDefaults() LogSchema {
+	d := DefaultLogSchema()
+	if s.TimestampField == "" {
+		s.TimestampField = d.TimestampField
+	}
+	if s.LevelField == "" {
+		s.LevelField = d.LevelField
+	}
+	if s.MessageField == "" {
+		s.MessageField = d.MessageField
+	}
+	if s.AttributesField == "" {
+		s.AttributesField = d.AttributesField
+	}
+	if s.SourceField == "" {
+		s.SourceField = d.SourceField
+	}
+	return s
+}
+
+// LogSource is the code location a log record comes from
+type LogSource struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// Document builds the document of a log record, empty schema fields use the DefaultLogSchema names
+func (s LogSchema) Document(t time.Time, level, msg string, attrs map[string]any, source *LogSource) map[string]any {
+	s = s.withDefaults()
+	doc := make(map[string]any, len(attrs)+4)
+
+	if s.FlattenAttributes {
+		for k, v := range attrs {
+			doc[k] = v
+		}
+	} else if len(attrs) > 0 {
+		doc[s.AttributesField] = attrs
+	}
+
+	if source != nil {
+		doc[s.SourceField] = source
+	}
+
+	doc[s.TimestampField] = t.UTC().Format(time.RFC3339Nano)
+	doc[s.LevelField] = level
+	doc[s.MessageField] = msg
+
+	return doc
+}
+
+// IndexConfig returns an index configuration matching the schema:
+// the timestamp field drives retention and pruning, the level is a tag field.
+func (s LogSchema) IndexConfig(indexID string) IndexConfig {
+	s = s.withDefaults()
+
+	fields := []FieldMapping{
+		{
+			Name:         s.TimestampField,
+			Type:         "datetime",
+			Fast:         true,
+			Indexed:      true,
+			Stored:       true,
+			InputFormats: []string{"rfc3339", "unix_timestamp"},
+			OutputFormat: "rfc3339",
+		},
+		{
+			Name:      s.LevelField,
+			Type:      "text",
+			Fast:      true,
+			Indexed:   true,
+			Stored:    true,
+			Tokenizer: "raw",
+		},
+		{
+			Name:      s.MessageField,
+			Type:      "text",
+			Indexed:   true,
+			Stored:    true,
+			Tokenizer: "default",
+			Record:    "position",
+		},
+	}
+
+	if !s.FlattenAttributes {
+		fields = append(fields, FieldMapping{
+			Name:       s.AttributesField,
+			Type:       "json",
+			Indexed:    true,
+			Stored:     true,
+			Tokenizer:  "raw",
+			ExpandDots: true,
+		})
+	}
+
+	// the version is left to CreateIndex, which picks the one matching the cluster
+	return IndexConfig{
+		ID: indexID,
+		DocMapping: DocMapping{
+			Mode:           "dynamic",
+			FieldMappings:  fields,
+			TagFields:      []any{s.LevelField},
+			TimestampField: s.TimestampField,
+		},
+		IndexingSettings: Settings{
+			CommitTimeoutSecs: 10,
+		},
+		SearchSettings: SearchSettings{
+			DefaultSearchFields: []string{s.MessageField},
+		},
+	}
+}
+
+// EnsureLogIndex creates the index described by the schema unless it already exists
+func EnsureLogIndex(ctx context.Context, c Client, indexID string, schema LogSchema) error {
+	_, err := c.GetIndex(ctx, indexID)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrIndexNotFound) {
+		return err
+	}
+
+	_, err = c.CreateIndex(ctx, schema.IndexConfig(indexID))
+	if err != nil && !errors.Is(err, ErrIndexAlreadyExists) {
+		return fmt.Errorf("cannot create log index: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package quickwit
+
+import (
+	"log/slog"
+)
+
+// Logger is the logging interface used by the client.
+// Arguments after the message are alternating keys and values, as with log/slog.
+type Logger interface {
+	Debug(msg string, keysAndValues ...any)
+	Info(msg string, keysAndValues ...any)
+	Warn(msg string, keysAndValues ...any)
+	Error(msg string, keysAndValues ...any)
+}
+
+// NewSlogLogger adapts a slog.Logger, slog.Default() is used when nil
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return l
+}
+
+type nopLogger struct{}
+
+// NewNopLogger returns a logger discarding everything
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+func (nopLogger) Debug(string, ...any) {}
+func (nopLogger) Info(string, ...any)  {}
+func (nopLogger) Warn(string, ...any)  {}
+func (nopLogger) Error(string, ...any) {}
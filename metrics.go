@@ -0,0 +1,400 @@
+package quickwit
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Names of the Quickwit metrics read by MetricsSnapshot.Summary and Diff
+const (
+	MetricIngestDocs          = "quickwit_ingest_docs_total"
+	MetricIngestBytes         = "quickwit_ingest_docs_bytes_total"
+	MetricSearchRequests      = "quickwit_search_root_search_requests_total"
+	MetricSearchDuration      = "quickwit_search_root_search_request_duration_seconds"
+	MetricSearchedSplits      = "quickwit_search_leaf_searches_splits_total"
+	MetricPendingMerges       = "quickwit_indexing_pending_merge_operations"
+	MetricOngoingMerges       = "quickwit_indexing_ongoing_merge_operations"
+	MetricCacheHits           = "quickwit_cache_hits_total"
+	MetricCacheMisses         = "quickwit_cache_misses_total"
+	metricCacheComponentLabel = "component_name"
+)
+
+type MetricSample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// matches tells whether the sample has every given label value
+func (s MetricSample) matches(labels map[string]string) bool {
+	for k, v := range labels {
+		if s.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+type MetricFamily struct {
+	Name string
+	Help string
+	// counter, gauge, histogram, summary or untyped
+	Type    string
+	Samples []MetricSample
+}
+
+// MetricsSnapshot holds the metrics of a node at a point in time
+type MetricsSnapshot struct {
+	Time time.Time
+	// Families by name, histogram samples (_bucket, _sum, _count) belong to their histogram family
+	Families map[string]*MetricFamily
+}
+
+// ParseMetrics parses metrics in the Prometheus text exposition format
+func ParseMetrics(r io.Reader) (*MetricsSnapshot, error) {
+	snapshot := &MetricsSnapshot{Time: time.Now(), Families: map[string]*MetricFamily{}}
+
+	family := func(name string) *MetricFamily {
+		f, ok := snapshot.Families[name]
+		if !ok {
+			f = &MetricFamily{Name: name, Type: "untyped"}
+			snapshot.Families[name] = f
+		}
+		return f
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		if comment, ok := strings.CutPrefix(text, "#"); ok {
+			fields := strings.SplitN(strings.TrimSpace(comment), " ", 3)
+			if len(fields) < 3 {
+				continue
+			}
+			switch fields[0] {
+			case "HELP":
+				family(fields[1]).Help = fields[2]
+			case "TYPE":
+				family(fields[1]).Type = fields[2]
+			}
+			continue
+		}
+
+		sample, err := parseSample(text)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse metrics line %d: %w", line, err)
+		}
+		f := family(snapshot.familyName(sample.Name))
+		f.Samples = append(f.Samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read metrics: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// familyName maps histogram and summary samples to the family declared by their TYPE line
+func (s *MetricsSnapshot) familyName(sample string) string {
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if base, ok := strings.CutSuffix(sample, suffix); ok {
+			if f, ok := s.Families[base]; ok && (f.Type == "histogram" || f.Type == "summary") {
+				return base
+			}
+		}
+	}
+	return sample
+}
+
+func parseSample(text string) (MetricSample, error) {
+	sample := MetricSample{Labels: map[string]string{}}
+
+	end := strings.IndexAny(text, "{ ")
+	if end <= 0 {
+		return sample, fmt.Errorf("missing value in %q", text)
+	}
+	sample.Name = text[:end]
+	rest := text[end:]
+
+	if strings.HasPrefix(rest, "{") {
+		var err error
+		if rest, err = parseLabels(rest[1:], sample.Labels); err != nil {
+			return sample, err
+		}
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return sample, fmt.Errorf("missing value in %q", text)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, fmt.Errorf("invalid value in %q: %w", text, err)
+	}
+	sample.Value = value
+
+	return sample, nil
+}
+
+// parseLabels reads name="value" pairs up to the closing brace and returns what follows it
+func parseLabels(s string, labels map[string]string) (string, error) {
+	for {
+		s = strings.TrimLeft(s, " ,")
+		if rest, ok := strings.CutPrefix(s, "}"); ok {
+			return rest, nil
+		}
+
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok || !strings.HasPrefix(rest, `"`) {
+			return "", fmt.Errorf("invalid labels %q", s)
+		}
+
+		value := strings.Builder{}
+		i := 1
+		for ; i < len(rest) && rest[i] != '"'; i++ {
+			if rest[i] == '\\' && i+1 < len(rest) {
+				i++
+				switch rest[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(rest[i])
+				}
+				continue
+			}
+			value.WriteByte(rest[i])
+		}
+		if i >= len(rest) {
+			return "", fmt.Errorf("unterminated label value %q", s)
+		}
+
+		labels[strings.TrimSpace(name)] = value.String()
+		s = rest[i+1:]
+	}
+}
+
+// Sum adds the samples of a metric having the given label values
+func (s *MetricsSnapshot) Sum(name string, labels map[string]string) float64 {
+	total := 0.0
+	f, ok := s.Families[s.familyName(name)]
+	if !ok {
+		return 0
+	}
+	for _, sample := range f.Samples {
+		if sample.Name == name && sample.matches(labels) {
+			total += sample.Value
+		}
+	}
+	return total
+}
+
+// buckets returns the cumulative histogram buckets of a metric summed over the series having the given label values
+func (s *MetricsSnapshot) buckets(name string, labels map[string]string) map[float64]float64 {
+	buckets := map[float64]float64{}
+	f, ok := s.Families[name]
+	if !ok {
+		return buckets
+	}
+	for _, sample := range f.Samples {
+		if sample.Name != name+"_bucket" || !sample.matches(labels) {
+			continue
+		}
+		le, err := strconv.ParseFloat(sample.Labels["le"], 64)
+		if err != nil {
+			continue
+		}
+		buckets[le] += sample.Value
+	}
+	return buckets
+}
+
+// Quantile estimates a quantile (0.99...) of a histogram metric, as Prometheus' histogram_quantile does
+func (s *MetricsSnapshot) Quantile(name string, q float64, labels map[string]string) float64 {
+	return bucketQuantile(s.buckets(name, labels), q)
+}
+
+func bucketQuantile(buckets map[float64]float64, q float64) float64 {
+	bounds := make([]float64, 0, len(buckets))
+	for le := range buckets {
+		bounds = append(bounds, le)
+	}
+	sort.Float64s(bounds)
+
+	if len(bounds) == 0 || buckets[bounds[len(bounds)-1]] == 0 {
+		return math.NaN()
+	}
+
+	rank := q * buckets[bounds[len(bounds)-1]]
+	lower, below := 0.0, 0.0
+	for _, le := range bounds {
+		count := buckets[le]
+		if count >= rank {
+			if math.IsInf(le, 1) {
+				return lower
+			}
+			if count == below {
+				return le
+			}
+			return lower + (le-lower)*(rank-below)/(count-below)
+		}
+		lower, below = le, count
+	}
+	return lower
+}
+
+// MetricsSummary gathers the key metrics of a node, counters are totals since the node started
+type MetricsSummary struct {
+	IngestedDocs   float64
+	IngestedBytes  float64
+	SearchRequests float64
+	SearchP50      time.Duration
+	SearchP99      time.Duration
+	SearchedSplits float64
+	PendingMerges  float64
+	OngoingMerges  float64
+	// Hit rate of each cache, between 0 and 1
+	CacheHitRates map[string]float64
+}
+
+func (s *MetricsSnapshot) Summary() MetricsSummary {
+	return MetricsSummary{
+		IngestedDocs:   s.Sum(MetricIngestDocs, nil),
+		IngestedBytes:  s.Sum(MetricIngestBytes, nil),
+		SearchRequests: s.Sum(MetricSearchRequests, nil),
+		SearchP50:      seconds(s.Quantile(MetricSearchDuration, 0.5, nil)),
+		SearchP99:      seconds(s.Quantile(MetricSearchDuration, 0.99, nil)),
+		SearchedSplits: s.Sum(MetricSearchedSplits, nil),
+		PendingMerges:  s.Sum(MetricPendingMerges, nil),
+		OngoingMerges:  s.Sum(MetricOngoingMerges, nil),
+		CacheHitRates:  cacheHitRates(s, nil),
+	}
+}
+
+// MetricsRates are the per-second rates between two snapshots, latencies are computed over the interval only
+type MetricsRates struct {
+	Interval          time.Duration
+	IngestDocsPerSec  float64
+	IngestBytesPerSec float64
+	SearchesPerSec    float64
+	SearchP50         time.Duration
+	SearchP99         time.Duration
+	SplitsPerSec      float64
+	PendingMerges     float64
+	CacheHitRates     map[string]float64
+}
+
+// Diff returns the rates between a previous snapshot and this one, counter resets are handled.
+// Without a previous snapshot, on the first poll, the rates are empty.
+func (s *MetricsSnapshot) Diff(prev *MetricsSnapshot) MetricsRates {
+	if prev == nil {
+		return MetricsRates{}
+	}
+
+	rates := MetricsRates{
+		Interval:      s.Time.Sub(prev.Time),
+		PendingMerges: s.Sum(MetricPendingMerges, nil),
+		CacheHitRates: cacheHitRates(s, prev),
+	}
+
+	rates.IngestDocsPerSec = s.Rate(prev, MetricIngestDocs, nil)
+	rates.IngestBytesPerSec = s.Rate(prev, MetricIngestBytes, nil)
+	rates.SearchesPerSec = s.Rate(prev, MetricSearchRequests, nil)
+	rates.SplitsPerSec = s.Rate(prev, MetricSearchedSplits, nil)
+
+	buckets := s.buckets(MetricSearchDuration, nil)
+	for le, count := range prev.buckets(MetricSearchDuration, nil) {
+		buckets[le] = counterDelta(count, buckets[le])
+	}
+	rates.SearchP50 = seconds(bucketQuantile(buckets, 0.5))
+	rates.SearchP99 = seconds(bucketQuantile(buckets, 0.99))
+
+	return rates
+}
+
+// Rate returns the per-second increase of a counter since a previous snapshot
+func (s *MetricsSnapshot) Rate(prev *MetricsSnapshot, name string, labels map[string]string) float64 {
+	if prev == nil {
+		return 0
+	}
+
+	interval := s.Time.Sub(prev.Time).Seconds()
+	if interval <= 0 {
+		return 0
+	}
+	return counterDelta(prev.Sum(name, labels), s.Sum(name, labels)) / interval
+}
+
+// counterDelta is the increase of a counter, which restarts from zero when the node restarts
+func counterDelta(prev, cur float64) float64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+// cacheHitRates returns the hit rate of each cache, since prev when it is not nil
+func cacheHitRates(s, prev *MetricsSnapshot) map[string]float64 {
+	rates := map[string]float64{}
+
+	components := map[string]bool{}
+	for _, name := range []string{MetricCacheHits, MetricCacheMisses} {
+		if f, ok := s.Families[name]; ok {
+			for _, sample := range f.Samples {
+				components[sample.Labels[metricCacheComponentLabel]] = true
+			}
+		}
+	}
+
+	for component := range components {
+		labels := map[string]string{metricCacheComponentLabel: component}
+		hits, misses := s.Sum(MetricCacheHits, labels), s.Sum(MetricCacheMisses, labels)
+		if prev != nil {
+			hits = counterDelta(prev.Sum(MetricCacheHits, labels), hits)
+			misses = counterDelta(prev.Sum(MetricCacheMisses, labels), misses)
+		}
+		if hits+misses > 0 {
+			rates[component] = hits / (hits + misses)
+		}
+	}
+
+	return rates
+}
+
+func seconds(s float64) time.Duration {
+	if math.IsNaN(s) {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// Metrics fetches the Prometheus metrics of the node answering, see TargetNode to pick the node
+func (c *client) Metrics(ctx context.Context) (*MetricsSnapshot, error) {
+	req, err := c.newRequest(ctx, Operation{Name: OpMetrics}, http.MethodGet, "/metrics", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot *MetricsSnapshot
+	err = send(c.doer, c.log, req, func(body io.Reader) error {
+		snapshot, err = ParseMetrics(body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
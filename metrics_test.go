@@ -0,0 +1,73 @@
+package quickwit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func metricsText(docs, searches int, buckets [3]int, hits, misses int) string {
+	return fmt.Sprintf(`# HELP quickwit_ingest_docs_total Total number of docs ingested.
+# TYPE quickwit_ingest_docs_total counter
+quickwit_ingest_docs_total{validity="valid"} %d
+quickwit_ingest_docs_total{validity="invalid"} 0
+# TYPE quickwit_search_root_search_requests_total counter
+quickwit_search_root_search_requests_total{kind="server",status="success"} %d
+# TYPE quickwit_search_root_search_request_duration_seconds histogram
+quickwit_search_root_search_request_duration_seconds_bucket{kind="server",le="0.1"} %d
+quickwit_search_root_search_request_duration_seconds_bucket{kind="server",le="1"} %d
+quickwit_search_root_search_request_duration_seconds_bucket{kind="server",le="+Inf"} %d
+quickwit_search_root_search_request_duration_seconds_sum{kind="server"} 12.5
+quickwit_search_root_search_request_duration_seconds_count{kind="server"} %d
+# TYPE quickwit_indexing_pending_merge_operations gauge
+quickwit_indexing_pending_merge_operations 3
+# TYPE quickwit_cache_hits_total counter
+quickwit_cache_hits_total{component_name="fastfields"} %d
+# TYPE quickwit_cache_misses_total counter
+quickwit_cache_misses_total{component_name="fastfields"} %d
+`, docs, searches, buckets[0], buckets[1], buckets[2], buckets[2], hits, misses)
+}
+
+func TestParseMetrics(t *testing.T) {
+	prev, err := ParseMetrics(strings.NewReader(metricsText(1000, 100, [3]int{50, 100, 100}, 30, 10)))
+	require.NoError(t, err)
+
+	assert.Equal(t, "counter", prev.Families[MetricIngestDocs].Type)
+	assert.Len(t, prev.Families[MetricSearchDuration].Samples, 5)
+	assert.Equal(t, 1000.0, prev.Sum(MetricIngestDocs, map[string]string{"validity": "valid"}))
+
+	summary := prev.Summary()
+	assert.Equal(t, 1000.0, summary.IngestedDocs)
+	assert.Equal(t, 100.0, summary.SearchRequests)
+	assert.Equal(t, 100*time.Millisecond, summary.SearchP50)
+	assert.Equal(t, 3.0, summary.PendingMerges)
+	assert.Equal(t, 0.75, summary.CacheHitRates["fastfields"])
+
+	cur, err := ParseMetrics(strings.NewReader(metricsText(3000, 120, [3]int{50, 120, 120}, 40, 40)))
+	require.NoError(t, err)
+	cur.Time = prev.Time.Add(10 * time.Second)
+
+	rates := cur.Diff(prev)
+	assert.Equal(t, 200.0, rates.IngestDocsPerSec)
+	assert.Equal(t, 2.0, rates.SearchesPerSec)
+	// the 20 searches of the interval all took between 100ms and 1s
+	assert.Equal(t, 550*time.Millisecond, rates.SearchP50)
+	assert.Equal(t, 0.25, rates.CacheHitRates["fastfields"])
+
+	// a restarted node starts its counters over
+	restarted, err := ParseMetrics(strings.NewReader(metricsText(500, 0, [3]int{0, 0, 0}, 0, 0)))
+	require.NoError(t, err)
+	restarted.Time = cur.Time.Add(10 * time.Second)
+	assert.Equal(t, 50.0, restarted.Diff(cur).IngestDocsPerSec)
+
+	// the first poll has nothing to compare with
+	assert.Equal(t, MetricsRates{}, cur.Diff(nil))
+	assert.Zero(t, cur.Rate(nil, MetricIngestDocs, nil))
+
+	_, err = ParseMetrics(strings.NewReader(`quickwit_broken{label="value} 1`))
+	assert.Error(t, err)
+}
@@ -1,70 +1,273 @@
 package quickwit
 
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
 type Cluster struct {
-	ClusterID  string `json:"cluster_id"`
-	SelfNodeID string `json:"self_node_id"`
-	ReadyNodes []struct {
-		NodeID              string `json:"node_id"`
-		GenerationID        int64  `json:"generation_id"`
-		GossipAdvertiseAddr string `json:"gossip_advertise_addr"`
-	} `json:"ready_nodes"`
-	LiveNodes             []any `json:"live_nodes"`
-	DeadNodes             []any `json:"dead_nodes"`
-	ChitchatStateSnapshot struct {
-		NodeStateSnapshots []struct {
-			ChitchatID struct {
-				NodeID              string `json:"node_id"`
-				GenerationID        int64  `json:"generation_id"`
-				GossipAdvertiseAddr string `json:"gossip_advertise_addr"`
-			} `json:"chitchat_id"`
-			NodeState struct {
-				ChitchatID struct {
-					NodeID              string `json:"node_id"`
-					GenerationID        int64  `json:"generation_id"`
-					GossipAdvertiseAddr string `json:"gossip_advertise_addr"`
-				} `json:"chitchat_id"`
-				Heartbeat int `json:"heartbeat"`
-				KeyValues struct {
-					EnabledServices struct {
-						Value   string `json:"value"`
-						Version int    `json:"version"`
-						Status  string `json:"status"`
-					} `json:"enabled_services"`
-					GrpcAdvertiseAddr struct {
-						Value   string `json:"value"`
-						Version int    `json:"version"`
-						Status  string `json:"status"`
-					} `json:"grpc_advertise_addr"`
-					IndexerTask01JKTRV3MFFPE8C0AVFJAW0JVK struct {
-						Value   string `json:"value"`
-						Version int    `json:"version"`
-						Status  string `json:"status"`
-					} `json:"indexer.task:01JKTRV3MFFPE8C0AVFJAW0JVK"`
-					IndexerTask01JKTRV3MFNMWYHD016JT86KEF struct {
-						Value   string `json:"value"`
-						Version int    `json:"version"`
-						Status  string `json:"status"`
-					} `json:"indexer.task:01JKTRV3MFNMWYHD016JT86KEF"`
-					IndexerTask01JKTRV3MFSYM43GAZE2C40ZC5 struct {
-						Value   string `json:"value"`
-						Version int    `json:"version"`
-						Status  string `json:"status"`
-					} `json:"indexer.task:01JKTRV3MFSYM43GAZE2C40ZC5"`
-					IndexingCPUCapacity struct {
-						Value   string `json:"value"`
-						Version int    `json:"version"`
-						Status  string `json:"status"`
-					} `json:"indexing_cpu_capacity"`
-					Readiness struct {
-						Value   string `json:"value"`
-						Version int    `json:"version"`
-						Status  string `json:"status"`
-					} `json:"readiness"`
-				} `json:"key_values"`
-				MaxVersion    int `json:"max_version"`
-				LastGcVersion int `json:"last_gc_version"`
-			} `json:"node_state"`
-		} `json:"node_state_snapshots"`
-		SeedAddrs []string `json:"seed_addrs"`
-	} `json:"chitchat_state_snapshot"`
+	ClusterID             string                `json:"cluster_id"`
+	SelfNodeID            string                `json:"self_node_id"`
+	ReadyNodes            []ChitchatID          `json:"ready_nodes"`
+	LiveNodes             []ChitchatID          `json:"live_nodes"`
+	DeadNodes             []ChitchatID          `json:"dead_nodes"`
+	ChitchatStateSnapshot ChitchatStateSnapshot `json:"chitchat_state_snapshot"`
+}
+
+// ChitchatID identifies a node incarnation, a restarted node gets a new generation
+type ChitchatID struct {
+	NodeID              string `json:"node_id"`
+	GenerationID        uint64 `json:"generation_id"`
+	GossipAdvertiseAddr string `json:"gossip_advertise_addr"`
+}
+
+type ChitchatStateSnapshot struct {
+	NodeStateSnapshots []NodeStateSnapshot `json:"node_state_snapshots"`
+	SeedAddrs          []string            `json:"seed_addrs"`
+}
+
+type NodeStateSnapshot struct {
+	ChitchatID ChitchatID `json:"chitchat_id"`
+	NodeState  NodeState  `json:"node_state"`
+}
+
+type NodeState struct {
+	ChitchatID    ChitchatID          `json:"chitchat_id"`
+	Heartbeat     uint64              `json:"heartbeat"`
+	KeyValues     map[string]KeyValue `json:"key_values"`
+	MaxVersion    uint64              `json:"max_version"`
+	LastGcVersion uint64              `json:"last_gc_version"`
+}
+
+type KeyValue struct {
+	Value   string         `json:"value"`
+	Version uint64         `json:"version"`
+	Status  KeyValueStatus `json:"status"`
+}
+
+// KeyValueStatus is "Set" for live values, deleted values have another status ("Deleted"...)
+type KeyValueStatus string
+
+const KeyValueSet KeyValueStatus = "Set"
+
+// UnmarshalJSON accepts both unit statuses ("Set") and statuses carrying data ({"Deleted": ...})
+func (s *KeyValueStatus) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		*s = KeyValueStatus(name)
+		return nil
+	}
+
+	var tagged map[string]json.RawMessage
+	if err := json.Unmarshal(b, &tagged); err != nil {
+		return err
+	}
+	for name := range tagged {
+		*s = KeyValueStatus(name)
+	}
+	return nil
+}
+
+// Well-known chitchat keys published by Quickwit nodes
+const (
+	KeyEnabledServices     = "enabled_services"
+	KeyGrpcAdvertiseAddr   = "grpc_advertise_addr"
+	KeyReadiness           = "readiness"
+	KeyIndexingCPUCapacity = "indexing_cpu_capacity"
+	KeyIndexerTaskPrefix   = "indexer.task:"
+)
+
+const ReadinessReady = "READY"
+
+// Quickwit services which can be enabled on a node
+const (
+	ServiceSearcher     = "searcher"
+	ServiceIndexer      = "indexer"
+	ServiceJanitor      = "janitor"
+	ServiceMetastore    = "metastore"
+	ServiceControlPlane = "control_plane"
+)
+
+// IndexingTask is an indexing pipeline assigned to an indexer
+type IndexingTask struct {
+	PipelineUID string
+	IndexUID    string
+	SourceID    string
+	ShardIDs    []string
+}
+
+// IndexID returns the index ID part of the index UID (index_id:incarnation)
+func (t IndexingTask) IndexID() string {
+	id, _, _ := strings.Cut(t.IndexUID, ":")
+	return id
+}
+
+// Get returns the value of a key which is not deleted
+func (s NodeState) Get(key string) (string, bool) {
+	kv, ok := s.KeyValues[key]
+	if !ok || (kv.Status != "" && kv.Status != KeyValueSet) {
+		return "", false
+	}
+	return kv.Value, true
+}
+
+func (s NodeState) EnabledServices() []string {
+	v, _ := s.Get(KeyEnabledServices)
+
+	services := []string{}
+	for _, service := range strings.Split(v, ",") {
+		if service = strings.TrimSpace(service); service != "" {
+			services = append(services, service)
+		}
+	}
+	return services
+}
+
+func (s NodeState) HasService(service string) bool {
+	for _, enabled := range s.EnabledServices() {
+		if enabled == service {
+			return true
+		}
+	}
+	return false
+}
+
+func (s NodeState) GrpcAdvertiseAddr() string {
+	v, _ := s.Get(KeyGrpcAdvertiseAddr)
+	return v
+}
+
+func (s NodeState) IsReady() bool {
+	v, _ := s.Get(KeyReadiness)
+	return v == ReadinessReady
+}
+
+// IndexingCPUCapacity returns the capacity in millicpus, published as "4000m" or "4"
+func (s NodeState) IndexingCPUCapacity() (int, bool) {
+	v, ok := s.Get(KeyIndexingCPUCapacity)
+	if !ok {
+		return 0, false
+	}
+
+	if millis, found := strings.CutSuffix(v, "m"); found {
+		n, err := strconv.Atoi(millis)
+		return n, err == nil
+	}
+
+	cpus, err := strconv.ParseFloat(v, 64)
+	return int(cpus * 1000), err == nil
+}
+
+// IndexingTasks returns the indexing pipelines of the node, sorted by pipeline UID.
+// Task values are formatted as index_id:incarnation:source_id:shard_ids.
+func (s NodeState) IndexingTasks() []IndexingTask {
+	tasks := []IndexingTask{}
+	for key := range s.KeyValues {
+		pipelineUID, ok := strings.CutPrefix(key, KeyIndexerTaskPrefix)
+		if !ok {
+			continue
+		}
+		v, ok := s.Get(key)
+		if !ok {
+			continue
+		}
+
+		task := IndexingTask{PipelineUID: pipelineUID}
+		sourceUID, shards, _ := cutLast(v, ":")
+		task.IndexUID, task.SourceID, _ = cutLast(sourceUID, ":")
+		for _, shard := range strings.Split(shards, ",") {
+			if shard != "" {
+				task.ShardIDs = append(task.ShardIDs, shard)
+			}
+		}
+		tasks = append(tasks, task)
+	}
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].PipelineUID < tasks[j].PipelineUID })
+	return tasks
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+// ClusterNode gathers what the cluster knows about a node
+type ClusterNode struct {
+	ChitchatID
+	Ready bool
+	Live  bool
+	Dead  bool
+	// Nil when the node is not in the chitchat snapshot
+	State *NodeState
+}
+
+// Nodes returns every node of the cluster, ready, live or dead, sorted by node ID
+func (c *Cluster) Nodes() []ClusterNode {
+	nodes := map[ChitchatID]*ClusterNode{}
+	get := func(id ChitchatID) *ClusterNode {
+		n, ok := nodes[id]
+		if !ok {
+			n = &ClusterNode{ChitchatID: id}
+			nodes[id] = n
+		}
+		return n
+	}
+
+	for _, id := range c.ReadyNodes {
+		get(id).Ready = true
+	}
+	for _, id := range c.LiveNodes {
+		get(id).Live = true
+	}
+	for _, id := range c.DeadNodes {
+		get(id).Dead = true
+	}
+	for i, s := range c.ChitchatStateSnapshot.NodeStateSnapshots {
+		get(s.ChitchatID).State = &c.ChitchatStateSnapshot.NodeStateSnapshots[i].NodeState
+	}
+
+	list := make([]ClusterNode, 0, len(nodes))
+	for _, n := range nodes {
+		list = append(list, *n)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].NodeID != list[j].NodeID {
+			return list[i].NodeID < list[j].NodeID
+		}
+		return list[i].GenerationID < list[j].GenerationID
+	})
+	return list
+}
+
+// LatestNodes returns the latest generation of every node, sorted by node ID
+func (c *Cluster) LatestNodes() []ClusterNode {
+	nodes := []ClusterNode{}
+	for _, n := range c.Nodes() {
+		// Nodes is sorted by generation within a node ID, a later generation replaces the previous one
+		if len(nodes) > 0 && nodes[len(nodes)-1].NodeID == n.NodeID {
+			nodes[len(nodes)-1] = n
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// Node returns the state of the node in the chitchat snapshot, the most recent generation when the node restarted
+func (c *Cluster) Node(nodeID string) (*NodeState, bool) {
+	var state *NodeState
+	var generation uint64
+	for i, s := range c.ChitchatStateSnapshot.NodeStateSnapshots {
+		if s.ChitchatID.NodeID == nodeID && (state == nil || s.ChitchatID.GenerationID > generation) {
+			state = &c.ChitchatStateSnapshot.NodeStateSnapshots[i].NodeState
+			generation = s.ChitchatID.GenerationID
+		}
+	}
+	return state, state != nil
 }
@@ -0,0 +1,78 @@
+package quickwit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const clusterFixture = `{
+  "cluster_id": "quickwit-default-cluster",
+  "self_node_id": "node-0",
+  "ready_nodes": [{"node_id": "node-0", "generation_id": 1739282000000, "gossip_advertise_addr": "172.17.0.2:7280"}],
+  "live_nodes": [{"node_id": "node-1", "generation_id": 1739282000001, "gossip_advertise_addr": "172.17.0.3:7280"}],
+  "dead_nodes": [{"node_id": "node-1", "generation_id": 1739281000000, "gossip_advertise_addr": "172.17.0.3:7280"}],
+  "chitchat_state_snapshot": {
+    "node_state_snapshots": [{
+      "chitchat_id": {"node_id": "node-0", "generation_id": 1739282000000, "gossip_advertise_addr": "172.17.0.2:7280"},
+      "node_state": {
+        "chitchat_id": {"node_id": "node-0", "generation_id": 1739282000000, "gossip_advertise_addr": "172.17.0.2:7280"},
+        "heartbeat": 1234,
+        "key_values": {
+          "enabled_services": {"value": "janitor,control_plane,indexer,searcher,metastore", "version": 1, "status": "Set"},
+          "grpc_advertise_addr": {"value": "172.17.0.2:7281", "version": 2, "status": "Set"},
+          "readiness": {"value": "READY", "version": 5, "status": "Set"},
+          "indexing_cpu_capacity": {"value": "4000m", "version": 3, "status": "Set"},
+          "indexer.task:01JKTRV3MFNMWYHD016JT86KEF": {"value": "otel-logs-v0_7:01JKTRV3MF0000000000000000:_ingest-source:1,2", "version": 6, "status": "Set"},
+          "indexer.task:01JKTRV3MFFPE8C0AVFJAW0JVK": {"value": "hdfs-logs:01JKTRV3MF0000000000000001:_ingest-source:", "version": 7, "status": "Set"},
+          "indexer.task:01JKTRV3MFSYM43GAZE2C40ZC5": {"value": "old:01JKTRV3MF0000000000000002:_ingest-source:3", "version": 8, "status": {"Deleted": 1739282000}}
+        },
+        "max_version": 8,
+        "last_gc_version": 0
+      }
+    }],
+    "seed_addrs": []
+  }
+}`
+
+func TestClusterModel(t *testing.T) {
+	cluster := Cluster{}
+	require.NoError(t, json.Unmarshal([]byte(clusterFixture), &cluster))
+
+	state, ok := cluster.Node("node-0")
+	require.True(t, ok)
+
+	assert.True(t, state.HasService(ServiceSearcher))
+	assert.Len(t, state.EnabledServices(), 5)
+	assert.Equal(t, "172.17.0.2:7281", state.GrpcAdvertiseAddr())
+	assert.True(t, state.IsReady())
+
+	capacity, ok := state.IndexingCPUCapacity()
+	assert.True(t, ok)
+	assert.Equal(t, 4000, capacity)
+
+	assert.Equal(t, []IndexingTask{
+		{PipelineUID: "01JKTRV3MFFPE8C0AVFJAW0JVK", IndexUID: "hdfs-logs:01JKTRV3MF0000000000000001", SourceID: "_ingest-source"},
+		{PipelineUID: "01JKTRV3MFNMWYHD016JT86KEF", IndexUID: "otel-logs-v0_7:01JKTRV3MF0000000000000000", SourceID: "_ingest-source", ShardIDs: []string{"1", "2"}},
+	}, state.IndexingTasks())
+	assert.Equal(t, "hdfs-logs", state.IndexingTasks()[0].IndexID())
+
+	nodes := cluster.Nodes()
+	require.Len(t, nodes, 3)
+	assert.True(t, nodes[0].Ready)
+	assert.NotNil(t, nodes[0].State)
+	// node-1 restarted, its previous generation is dead
+	assert.Equal(t, "node-1", nodes[1].NodeID)
+	assert.True(t, nodes[1].Dead)
+	assert.True(t, nodes[2].Live)
+	assert.Nil(t, nodes[2].State)
+
+	latest := cluster.LatestNodes()
+	require.Len(t, latest, 2)
+	assert.Equal(t, "node-0", latest[0].NodeID)
+	assert.Equal(t, uint64(1739282000001), latest[1].GenerationID)
+	assert.True(t, latest[1].Live)
+	assert.False(t, latest[1].Dead)
+}
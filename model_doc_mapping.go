@@ -7,15 +7,32 @@ type DocMapping struct {
 	IndexFieldPresence bool           `json:"index_field_presence,omitempty" yaml:"index_field_presence,omitempty"`
 	TimestampField     string         `json:"timestamp_field,omitempty" yaml:"timestamp_field,omitempty"`
 	Mode               string         `json:"mode" yaml:"mode"`
+	PartitionKey       string         `json:"partition_key,omitempty" yaml:"partition_key,omitempty"`
 	MaxNumPartitions   int            `json:"max_num_partitions,omitempty" yaml:"max_num_partitions,omitempty"`
 	Tokenizers         []any          `json:"tokenizers,omitempty" yaml:"tokenizers,omitempty"`
-	// UID                string                  `json:"doc_mapping_uid,omitempty" yaml:"doc_mapping_uid,omitempty"`
-	// StoreDocumentSize  bool           `json:"store_document_size" yaml:"store_document_size"`
+	// Mapping of the fields which are not in FieldMappings, with the dynamic mode
+	DynamicMapping    *DynamicMapping `json:"dynamic_mapping,omitempty" yaml:"dynamic_mapping,omitempty"`
+	StoreDocumentSize bool            `json:"store_document_size,omitempty" yaml:"store_document_size,omitempty"`
+	// Set by the cluster, it changes with every doc mapping update
+	UID string `json:"doc_mapping_uid,omitempty" yaml:"doc_mapping_uid,omitempty"`
+}
+
+// DynamicMapping holds the options of the dynamic fields, nil values keep the Quickwit defaults
+type DynamicMapping struct {
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Indexed     *bool  `json:"indexed,omitempty" yaml:"indexed,omitempty"`
+	Stored      *bool  `json:"stored,omitempty" yaml:"stored,omitempty"`
+	Tokenizer   string `json:"tokenizer,omitempty" yaml:"tokenizer,omitempty"`
+	Record      string `json:"record,omitempty" yaml:"record,omitempty"`
+	Fieldnorms  bool   `json:"fieldnorms,omitempty" yaml:"fieldnorms,omitempty"`
+	Fast        any    `json:"fast,omitempty" yaml:"fast,omitempty"`
+	ExpandDots  *bool  `json:"expand_dots,omitempty" yaml:"expand_dots,omitempty"`
 }
 
 type FieldMapping struct {
 	Name          string   `json:"name" yaml:"name"`
 	Type          string   `json:"type" yaml:"type"`
+	Description   string   `json:"description,omitempty" yaml:"description,omitempty"`
 	Fast          any      `json:"fast,omitempty" yaml:"fast,omitempty"`
 	FastPrecision string   `json:"fast_precision,omitempty" yaml:"fast_precision,omitempty"`
 	Indexed       bool     `json:"indexed" yaml:"indexed"`
@@ -28,4 +45,9 @@ type FieldMapping struct {
 	Tokenizer     string   `json:"tokenizer,omitempty" yaml:"tokenizer,omitempty"`
 	Coerce        bool     `json:"coerce,omitempty" yaml:"coerce,omitempty"`
 	ExpandDots    bool     `json:"expand_dots,omitempty" yaml:"expand_dots,omitempty"`
+	// Fields of an object field
+	FieldMappings []FieldMapping `json:"field_mappings,omitempty" yaml:"field_mappings,omitempty"`
+	// Fields of a concatenate field
+	ConcatenateFields    []string `json:"concatenate_fields,omitempty" yaml:"concatenate_fields,omitempty"`
+	IncludeDynamicFields bool     `json:"include_dynamic_fields,omitempty" yaml:"include_dynamic_fields,omitempty"`
 }
@@ -1,6 +1,105 @@
 package quickwit
 
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
 type ErrorMsg struct {
 	Message string `json:"message"`
 	Error   string `json:"error"`
 }
+
+// Sentinel errors matched by *APIError with errors.Is
+var (
+	ErrIndexNotFound      = errors.New("quickwit: index not found")
+	ErrIndexAlreadyExists = errors.New("quickwit: index already exists")
+	ErrSourceNotFound     = errors.New("quickwit: source not found")
+	ErrUnauthorized       = errors.New("quickwit: unauthorized")
+	ErrRateLimited        = errors.New("quickwit: rate limited")
+)
+
+// APIError is returned when Quickwit answers with a non 2xx status code
+type APIError struct {
+	StatusCode int
+	Method     string
+	Path       string
+	// Name of the client operation, see OperationFromContext
+	Operation string
+	// Quickwit's "message" and "error" fields
+	Message      string
+	ErrorMessage string
+	// Raw response body, when it is not a Quickwit error payload
+	Body string
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message + e.ErrorMessage
+	if msg == "" {
+		msg = e.Body
+	}
+
+	return fmt.Sprintf("quickwit error: %d - %s", e.StatusCode, msg)
+}
+
+func (e *APIError) Is(target error) bool {
+	msg := strings.ToLower(e.Message + " " + e.ErrorMessage + " " + e.Body)
+
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrIndexNotFound:
+		if e.StatusCode != http.StatusNotFound || e.isAboutSource(msg) {
+			return false
+		}
+		if strings.Contains(msg, "index") {
+			return isNotFoundMessage(msg)
+		}
+		// without an explicit message, only a 404 on an index route means the index is missing
+		return strings.TrimSpace(msg) == "" && indexOperations[e.Operation]
+	case ErrSourceNotFound:
+		if e.StatusCode != http.StatusNotFound || !e.isAboutSource(msg) {
+			return false
+		}
+		return isNotFoundMessage(msg) || (strings.TrimSpace(msg) == "" && e.Operation == OpDeleteSource)
+	case ErrIndexAlreadyExists:
+		return (e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusConflict) &&
+			strings.Contains(msg, "already exist") && !e.isAboutSource(msg)
+	default:
+		return false
+	}
+}
+
+// indexOperations target a single existing index
+var indexOperations = map[string]bool{
+	OpGetIndex:      true,
+	OpUpdateIndex:   true,
+	OpDeleteIndex:   true,
+	OpClearIndex:    true,
+	OpDescribeIndex: true,
+	OpListSplits:    true,
+	OpSearch:        true,
+	OpIngest:        true,
+}
+
+// isNotFoundMessage matches Quickwit's messages, e.g. "index `logs` not found" or
+// "could not find indexes matching the IDs ..."
+func isNotFoundMessage(msg string) bool {
+	return strings.Contains(msg, "not found") || strings.Contains(msg, "could not find") || strings.Contains(msg, "does not exist")
+}
+
+// isAboutSource tells whether the error concerns a source rather than an index,
+// relying on the path when Quickwit's message is not explicit
+func (e *APIError) isAboutSource(msg string) bool {
+	if strings.Contains(msg, "source") {
+		return true
+	}
+	if strings.Contains(msg, "index") {
+		return false
+	}
+	return strings.Contains(e.Path, "/sources/")
+}
@@ -0,0 +1,104 @@
+package quickwit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		err     APIError
+		matches []error
+	}{
+		{
+			name:    "index not found",
+			err:     APIError{StatusCode: 404, Path: "/api/v1/indexes/logs", Operation: OpGetIndex, Message: "index `logs` not found"},
+			matches: []error{ErrIndexNotFound},
+		},
+		{
+			name:    "search on missing index",
+			err:     APIError{StatusCode: 404, Path: "/api/v1/logs/search", Operation: OpSearch, Message: "could not find indexes matching the IDs `[\"logs\"]`"},
+			matches: []error{ErrIndexNotFound},
+		},
+		{
+			name:    "empty 404 on an index operation",
+			err:     APIError{StatusCode: 404, Path: "/api/v1/indexes/logs", Operation: OpDeleteIndex},
+			matches: []error{ErrIndexNotFound},
+		},
+		{
+			name: "unknown route",
+			err:  APIError{StatusCode: 404, Path: "/api/v1/unknown", Operation: OpVersion, Body: "Route not found"},
+		},
+		{
+			name: "empty 404 on the cluster endpoint",
+			err:  APIError{StatusCode: 404, Path: "/api/v1/cluster", Operation: OpGetCluster},
+		},
+		{
+			name:    "source not found",
+			err:     APIError{StatusCode: 404, Path: "/api/v1/indexes/logs/sources/kafka", Operation: OpDeleteSource, Message: "source `logs:kafka` not found"},
+			matches: []error{ErrSourceNotFound},
+		},
+		{
+			name:    "index of a source not found",
+			err:     APIError{StatusCode: 404, Path: "/api/v1/indexes/logs/sources/kafka", Operation: OpDeleteSource, Message: "index `logs` not found"},
+			matches: []error{ErrIndexNotFound},
+		},
+		{
+			name:    "empty 404 on a source",
+			err:     APIError{StatusCode: 404, Path: "/api/v1/indexes/logs/sources/kafka", Operation: OpDeleteSource},
+			matches: []error{ErrSourceNotFound},
+		},
+		{
+			name:    "index already exists",
+			err:     APIError{StatusCode: 400, Operation: OpCreateIndex, Message: "index `logs` already exists"},
+			matches: []error{ErrIndexAlreadyExists},
+		},
+		{
+			name: "source already exists",
+			err:  APIError{StatusCode: 400, Operation: OpCreateSource, Message: "source `kafka` already exists"},
+		},
+		{
+			name:    "unauthorized",
+			err:     APIError{StatusCode: 403},
+			matches: []error{ErrUnauthorized},
+		},
+		{
+			name:    "rate limited",
+			err:     APIError{StatusCode: 429},
+			matches: []error{ErrRateLimited},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, sentinel := range []error{ErrIndexNotFound, ErrSourceNotFound, ErrIndexAlreadyExists, ErrUnauthorized, ErrRateLimited} {
+				expected := false
+				for _, m := range tt.matches {
+					expected = expected || m == sentinel
+				}
+				assert.Equal(t, expected, errors.Is(&tt.err, sentinel), sentinel.Error())
+			}
+		})
+	}
+}
+
+func TestAPIErrorOperation(t *testing.T) {
+	doer := doerFunc(func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusNotFound, ``), nil
+	})
+	c := New(WithDoer(doer), WithLogger(NewNopLogger()))
+
+	_, err := c.GetIndex(context.Background(), "logs")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, OpGetIndex, apiErr.Operation)
+	assert.ErrorIs(t, err, ErrIndexNotFound)
+
+	_, err = c.GetCluster(context.Background())
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrIndexNotFound)
+}
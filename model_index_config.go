@@ -15,5 +15,5 @@ type IndexRetention struct {
 	Period string `json:"period,omitempty" yaml:"period,omitempty"`
 	//Frequency at which the retention policy is evaluated and applied
 	// expressed as a cron expression (0 0 * * * *) or human-readable form (hourly, daily, weekly, monthly, yearly).
-	Schedule string `json:"schedule,omitempty" yaml:"retention,omitempty"`
+	Schedule string `json:"schedule,omitempty" yaml:"schedule,omitempty"`
 }
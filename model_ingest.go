@@ -0,0 +1,8 @@
+package quickwit
+
+// IngestResponse is returned by the ingest endpoint
+type IngestResponse struct {
+	NumDocsForProcessing int `json:"num_docs_for_processing" yaml:"num_docs_for_processing"`
+	NumIngestedDocs      int `json:"num_ingested_docs,omitempty" yaml:"num_ingested_docs,omitempty"`
+	NumRejectedDocs      int `json:"num_rejected_docs,omitempty" yaml:"num_rejected_docs,omitempty"`
+}
@@ -0,0 +1,31 @@
+package quickwit
+
+// NodeConfig is the node config file (quickwit.yaml), service sections are kept untyped
+type NodeConfig struct {
+	Version             string         `json:"version" yaml:"version"`
+	ClusterID           string         `json:"cluster_id,omitempty" yaml:"cluster_id,omitempty"`
+	NodeID              string         `json:"node_id,omitempty" yaml:"node_id,omitempty"`
+	EnabledServices     []string       `json:"enabled_services,omitempty" yaml:"enabled_services,omitempty"`
+	ListenAddress       string         `json:"listen_address,omitempty" yaml:"listen_address,omitempty"`
+	AdvertiseAddress    string         `json:"advertise_address,omitempty" yaml:"advertise_address,omitempty"`
+	Rest                *RestConfig    `json:"rest,omitempty" yaml:"rest,omitempty"`
+	GossipListenPort    int            `json:"gossip_listen_port,omitempty" yaml:"gossip_listen_port,omitempty"`
+	GrpcListenPort      int            `json:"grpc_listen_port,omitempty" yaml:"grpc_listen_port,omitempty"`
+	PeerSeeds           []string       `json:"peer_seeds,omitempty" yaml:"peer_seeds,omitempty"`
+	DataDir             string         `json:"data_dir,omitempty" yaml:"data_dir,omitempty"`
+	MetastoreURI        string         `json:"metastore_uri,omitempty" yaml:"metastore_uri,omitempty"`
+	DefaultIndexRootURI string         `json:"default_index_root_uri,omitempty" yaml:"default_index_root_uri,omitempty"`
+	Grpc                map[string]any `json:"grpc,omitempty" yaml:"grpc,omitempty"`
+	Storage             map[string]any `json:"storage,omitempty" yaml:"storage,omitempty"`
+	Metastore           map[string]any `json:"metastore,omitempty" yaml:"metastore,omitempty"`
+	Indexer             map[string]any `json:"indexer,omitempty" yaml:"indexer,omitempty"`
+	IngestAPI           map[string]any `json:"ingest_api,omitempty" yaml:"ingest_api,omitempty"`
+	Searcher            map[string]any `json:"searcher,omitempty" yaml:"searcher,omitempty"`
+	Jaeger              map[string]any `json:"jaeger,omitempty" yaml:"jaeger,omitempty"`
+}
+
+type RestConfig struct {
+	ListenPort       int               `json:"listen_port,omitempty" yaml:"listen_port,omitempty"`
+	CorsAllowOrigins []string          `json:"cors_allow_origins,omitempty" yaml:"cors_allow_origins,omitempty"`
+	ExtraHeaders     map[string]string `json:"extra_headers,omitempty" yaml:"extra_headers,omitempty"`
+}
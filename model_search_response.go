@@ -5,3 +5,7 @@ type SearchResponse struct {
 	NumHits           int `json:"num_hits"`
 	ElapsedTimeMicros int `json:"elapsed_time_micros"`
 }
+
+func (r *SearchResponse) hitsCount() int {
+	return r.NumHits
+}
@@ -19,8 +19,11 @@ type MergePolicy struct {
 	MergeFactor      int    `json:"merge_factor,omitempty" yaml:"merge_factor,omitempty"`
 	MaxMergeFactor   int    `json:"max_merge_factor,omitempty" yaml:"max_merge_factor,omitempty"`
 	MaturationPeriod string `json:"maturation_period,omitempty" yaml:"maturation_period,omitempty"`
+	// Merge operations of the limit_merge policy
+	MaxMergeOps int `json:"max_merge_ops,omitempty" yaml:"max_merge_ops,omitempty"`
 }
 
 type Resources struct {
-	HeapSize string `json:"heap_size,omitempty" yaml:"heap_size,omitempty"`
+	HeapSize                string `json:"heap_size,omitempty" yaml:"heap_size,omitempty"`
+	MaxMergeWriteThroughput string `json:"max_merge_write_throughput,omitempty" yaml:"max_merge_write_throughput,omitempty"`
 }
@@ -1,25 +1,32 @@
 package quickwit
 
+// DefaultSourceInputFormat is the input format of the sources which do not set one
+const DefaultSourceInputFormat = "json"
+
 type Source struct {
-	ID           string `json:"source_id" yaml:"source_id"`
-	Version      string `json:"version" yaml:"version"`
-	NumPipelines int    `json:"num_pipelines" yaml:"num_pipelines"`
-	Enabled      bool   `json:"enabled" yaml:"enabled"`
-	SourceType   string `json:"source_type" yaml:"source_type"`
-	InputFormat  string `json:"input_format" yaml:"input_format"`
+	ID           string         `json:"source_id" yaml:"source_id"`
+	Version      string         `json:"version" yaml:"version"`
+	NumPipelines int            `json:"num_pipelines" yaml:"num_pipelines"`
+	Enabled      bool           `json:"enabled" yaml:"enabled"`
+	SourceType   string         `json:"source_type" yaml:"source_type"`
+	InputFormat  string         `json:"input_format" yaml:"input_format"`
+	Transform    map[string]any `json:"transform,omitempty" yaml:"transform,omitempty"`
+	Params       map[string]any `json:"params,omitempty" yaml:"params,omitempty"`
 }
 
 type SourceConfig struct {
-	Version       string         `json:"version"`
-	ID            string         `json:"source_id"`
-	Type          string         `json:"source_type"`
-	PipelineCount int            `json:"num_pipelines,omitempty"`
-	Params        map[string]any `json:"params"`
+	Version       string         `json:"version" yaml:"version"`
+	ID            string         `json:"source_id" yaml:"source_id"`
+	Type          string         `json:"source_type" yaml:"source_type"`
+	PipelineCount int            `json:"num_pipelines,omitempty" yaml:"num_pipelines,omitempty"`
+	InputFormat   string         `json:"input_format,omitempty" yaml:"input_format,omitempty"`
+	Transform     map[string]any `json:"transform,omitempty" yaml:"transform,omitempty"`
+	Params        map[string]any `json:"params" yaml:"params"`
 }
 
+// NewPulsarSourceConfig leaves the version empty, CreateSource sets the one of the cluster
 func NewPulsarSourceConfig(sourceID, endpoint, token, topic string) SourceConfig {
 	src := SourceConfig{
-		Version:       "0.9",
 		ID:            sourceID,
 		Type:          "pulsar",
 		PipelineCount: 1,
@@ -0,0 +1,384 @@
+package quickwit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+type BalancingPolicy int
+
+const (
+	// RoundRobin sends requests to each available node in turn
+	RoundRobin BalancingPolicy = iota
+	// LeastInFlight sends requests to the available node with the fewest requests in flight
+	LeastInFlight
+)
+
+const (
+	DefaultDiscoveryInterval = 30 * time.Second
+	DefaultEjectAfter        = 3
+	DefaultEjectFor          = 30 * time.Second
+)
+
+var ErrNoNodes = errors.New("quickwit: no node available")
+
+type NodePoolConfig struct {
+	// Endpoints used until nodes are discovered, e.g. http://quickwit-0:7280
+	Seeds    []string
+	Balancer BalancingPolicy
+	// Interval between two discoveries of the cluster ready nodes, negative to disable discovery
+	DiscoveryInterval time.Duration
+	// Port of the REST API of discovered nodes, defaults to their gossip port which Quickwit binds to the REST port by default
+	RESTPort int
+	// Consecutive failures ejecting a node
+	EjectAfter int
+	// Time an ejected node is left out before receiving requests again
+	EjectFor time.Duration
+	// Decides whether a request failed, defaults to network errors and 502, 503 or 504 responses
+	IsFailure func(res *http.Response, err error) bool
+	// Called when discovery changes the node list
+	OnNodesChange func(endpoints []string)
+}
+
+func (cfg NodePoolConfig) withDefaults() NodePoolConfig {
+	if cfg.DiscoveryInterval == 0 {
+		cfg.DiscoveryInterval = DefaultDiscoveryInterval
+	}
+	if cfg.EjectAfter <= 0 {
+		cfg.EjectAfter = DefaultEjectAfter
+	}
+	if cfg.EjectFor <= 0 {
+		cfg.EjectFor = DefaultEjectFor
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = isNodeFailure
+	}
+	return cfg
+}
+
+func isNodeFailure(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// NodeStatus describes a node of the pool
+type NodeStatus struct {
+	Endpoint string
+	InFlight int
+	Requests uint64
+	Failures uint64
+	// Consecutive failures since the last success
+	ConsecutiveFailures int
+	// Zero when the node is not ejected
+	EjectedUntil time.Time
+}
+
+// NodePool spreads requests over the nodes of a cluster, ejects failing nodes and fails over to the others.
+// Nodes are discovered from the ready nodes of the cluster, the seeds are used until the first discovery.
+type NodePool struct {
+	cfg    NodePoolConfig
+	scheme string
+
+	mu    sync.Mutex
+	nodes []*node
+	next  int
+
+	startOnce sync.Once
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+type node struct {
+	NodeStatus
+	url *url.URL
+}
+
+func NewNodePool(cfg NodePoolConfig) (*NodePool, error) {
+	cfg = cfg.withDefaults()
+	if len(cfg.Seeds) == 0 {
+		return nil, errors.New("node pool needs at least one seed")
+	}
+
+	p := &NodePool{cfg: cfg, done: make(chan struct{})}
+	for _, seed := range cfg.Seeds {
+		u, err := url.Parse(seed)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid seed %q", seed)
+		}
+		p.nodes = append(p.nodes, &node{NodeStatus: NodeStatus{Endpoint: u.Scheme + "://" + u.Host}, url: u})
+	}
+	p.scheme = p.nodes[0].url.Scheme
+
+	return p, nil
+}
+
+// Nodes returns the status of each node of the pool
+func (p *NodePool) Nodes() []NodeStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := make([]NodeStatus, len(p.nodes))
+	for i, n := range p.nodes {
+		status[i] = n.NodeStatus
+	}
+	return status
+}
+
+// Close stops the background discovery
+func (p *NodePool) Close() {
+	p.closeOnce.Do(func() { close(p.done) })
+}
+
+// Discover replaces the nodes of the pool with the ready nodes of the cluster.
+// The nodes are kept when the cluster has no ready node.
+func (p *NodePool) Discover(ctx context.Context, c Client) error {
+	cluster, err := c.GetCluster(ctx)
+	if err != nil {
+		return err
+	}
+
+	endpoints := []string{}
+	for _, n := range cluster.ReadyNodes {
+		endpoint, err := nodeEndpoint(p.scheme, n.GossipAdvertiseAddr, p.cfg.RESTPort)
+		if err != nil {
+			continue
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	if p.setNodes(endpoints) && p.cfg.OnNodesChange != nil {
+		p.cfg.OnNodesChange(endpoints)
+	}
+	return nil
+}
+
+// setNodes keeps the state of the nodes still present, it reports whether the list changed
+func (p *NodePool) setNodes(endpoints []string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	current := make(map[string]*node, len(p.nodes))
+	for _, n := range p.nodes {
+		current[n.Endpoint] = n
+	}
+
+	changed := len(endpoints) != len(p.nodes)
+	nodes := make([]*node, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if n, ok := current[endpoint]; ok {
+			nodes = append(nodes, n)
+			continue
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			continue
+		}
+		changed = true
+		nodes = append(nodes, &node{NodeStatus: NodeStatus{Endpoint: endpoint}, url: u})
+	}
+
+	p.nodes = nodes
+	return changed
+}
+
+// startDiscovery refreshes the nodes periodically until Close, only the first client sharing the pool runs it
+func (p *NodePool) startDiscovery(c Client, log Logger) {
+	if p.cfg.DiscoveryInterval < 0 {
+		return
+	}
+
+	p.startOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(p.cfg.DiscoveryInterval)
+			defer ticker.Stop()
+
+			for {
+				ctx, cancel := context.WithTimeout(context.Background(), p.cfg.DiscoveryInterval)
+				if err := p.Discover(ctx, c); err != nil {
+					log.Warn("cannot discover quickwit nodes", "error", err)
+				}
+				cancel()
+
+				select {
+				case <-p.done:
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	})
+}
+
+// pick returns the node to send the next request to, skipping the tried ones and the ejected ones.
+// Nodes already used by the hedges of the request are only picked when no other node is available.
+// When every untried node is ejected, the one returning first is used.
+func (p *NodePool) pick(tried map[*node]bool, hedges *hedgeGroup) *node {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var picked, hedged, fallback *node
+	for i := range p.nodes {
+		n := p.nodes[(p.next+i)%len(p.nodes)]
+		if tried[n] {
+			continue
+		}
+		if hedges.used(n.Endpoint) {
+			if hedged == nil {
+				hedged = n
+			}
+			continue
+		}
+		if now.Before(n.EjectedUntil) {
+			if fallback == nil || n.EjectedUntil.Before(fallback.EjectedUntil) {
+				fallback = n
+			}
+			continue
+		}
+		if picked == nil || (p.cfg.Balancer == LeastInFlight && n.InFlight < picked.InFlight) {
+			picked = n
+		}
+		if p.cfg.Balancer == RoundRobin {
+			break
+		}
+	}
+	if picked == nil {
+		picked = hedged
+	}
+	if picked == nil {
+		picked = fallback
+	}
+	if picked == nil {
+		return nil
+	}
+	hedges.use(picked.Endpoint)
+
+	p.next = (p.next + 1) % len(p.nodes)
+	picked.InFlight++
+	picked.Requests++
+
+	return picked
+}
+
+// pin returns the endpoint of the node the next request would be sent to, for calls which must all reach the same node
+func (p *NodePool) pin() (string, error) {
+	n := p.pick(map[*node]bool{}, nil)
+	if n == nil {
+		return "", ErrNoNodes
+	}
+	p.finish(n, nil)
+
+	return n.Endpoint, nil
+}
+
+// finish records the outcome of a request, a nil outcome only releases the node
+func (p *NodePool) finish(n *node, failed *bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n.InFlight--
+	if failed == nil {
+		return
+	}
+	if !*failed {
+		n.ConsecutiveFailures = 0
+		n.EjectedUntil = time.Time{}
+		return
+	}
+
+	n.Failures++
+	n.ConsecutiveFailures++
+	if n.ConsecutiveFailures >= p.cfg.EjectAfter {
+		n.ConsecutiveFailures = 0
+		n.EjectedUntil = time.Now().Add(p.cfg.EjectFor)
+	}
+}
+
+// Middleware sends each request to a node of the pool.
+// Requests which were not sent, or are safe to retry (see MarkRetrySafe), fail over to the other nodes.
+func (p *NodePool) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			if _, ok := targetNodeFromContext(ctx); ok {
+				return next.RoundTrip(req)
+			}
+
+			tried := map[*node]bool{}
+			hedges := hedgeGroupFromContext(ctx)
+
+			for {
+				n := p.pick(tried, hedges)
+				if n == nil {
+					return nil, ErrNoNodes
+				}
+				tried[n] = true
+
+				r := req.Clone(ctx)
+				r.URL.Scheme, r.URL.Host, r.Host = n.url.Scheme, n.url.Host, ""
+
+				res, err := next.RoundTrip(r)
+
+				// a cancelled call, or an interceptor failure, says nothing about the node health
+				if ctx.Err() != nil || isInterceptorError(err) {
+					p.finish(n, nil)
+					return res, err
+				}
+
+				failed := p.cfg.IsFailure(res, err)
+				if res != nil && !failed {
+					res.Body = &releaseOnClose{ReadCloser: res.Body, release: func() { p.finish(n, &failed) }}
+					return res, nil
+				}
+				p.finish(n, &failed)
+
+				if !failed || !canFailover(req, err) || len(tried) >= p.size() {
+					return res, err
+				}
+				if res != nil {
+					_ = res.Body.Close()
+				}
+				if req, err = rewindRequest(req); err != nil {
+					return nil, err
+				}
+			}
+		})
+	}
+}
+
+func (p *NodePool) size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.nodes)
+}
+
+// canFailover reports whether the request can be sent to another node: it must be rewindable and either
+// safe to retry or known not to have reached the node
+func canFailover(req *http.Request, err error) bool {
+	if !canRewind(req) {
+		return false
+	}
+	if isRetrySafe(req) {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.Is(err, ErrCircuitOpen) || (errors.As(err, &opErr) && opErr.Op == "dial")
+}
@@ -0,0 +1,88 @@
+package quickwit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodePool(t *testing.T) {
+	hits := [2]atomic.Int32{}
+	servers := []*httptest.Server{}
+	for i := range hits {
+		servers = append(servers, httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[i].Add(1)
+			_, _ = fmt.Fprint(w, `[]`)
+		})))
+		defer servers[i].Close()
+	}
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	pool, err := NewNodePool(NodePoolConfig{
+		Seeds:             []string{servers[0].URL, down.URL, servers[1].URL},
+		DiscoveryInterval: -1,
+		EjectAfter:        1,
+		EjectFor:          time.Minute,
+	})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	c := New(WithNodePool(pool), WithLogger(NewNopLogger()))
+
+	for range 6 {
+		_, err := c.ListIndexes(context.Background())
+		require.NoError(t, err)
+	}
+
+	// the failed request moved to the next node and the dead one got ejected
+	assert.Equal(t, int32(6), hits[0].Load()+hits[1].Load())
+	assert.Greater(t, hits[0].Load(), int32(1))
+	assert.Greater(t, hits[1].Load(), int32(1))
+
+	for _, n := range pool.Nodes() {
+		assert.Zero(t, n.InFlight, n.Endpoint)
+		if n.Endpoint == down.URL {
+			assert.Equal(t, uint64(1), n.Requests)
+			assert.False(t, n.EjectedUntil.IsZero())
+		}
+	}
+}
+
+func TestNodePoolDiscovery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"ready_nodes": [
+			{"node_id": "a", "gossip_advertise_addr": "10.0.0.1:7280"},
+			{"node_id": "b", "gossip_advertise_addr": "10.0.0.2:7280"}
+		]}`)
+	}))
+	defer srv.Close()
+
+	var changed []string
+	pool, err := NewNodePool(NodePoolConfig{
+		Seeds:             []string{srv.URL},
+		DiscoveryInterval: -1,
+		RESTPort:          7281,
+		OnNodesChange:     func(endpoints []string) { changed = endpoints },
+	})
+	require.NoError(t, err)
+
+	c := New(WithEndpoint(srv.URL), WithLogger(NewNopLogger()))
+	require.NoError(t, pool.Discover(context.Background(), c))
+
+	assert.Equal(t, []string{"http://10.0.0.1:7281", "http://10.0.0.2:7281"}, changed)
+	endpoints := []string{}
+	for _, n := range pool.Nodes() {
+		endpoints = append(endpoints, n.Endpoint)
+	}
+	assert.Equal(t, "http://10.0.0.1:7281,http://10.0.0.2:7281", strings.Join(endpoints, ","))
+}
@@ -0,0 +1,84 @@
+package qwlogrus
+
+import (
+	"context"
+	"errors"
+
+	quickwit "github.com/CleverCloud/quickwit-go"
+	"github.com/sirupsen/logrus"
+)
+
+type HookConfig struct {
+	// Levels fired by the hook, defaults to logrus.AllLevels
+	Levels   []logrus.Level
+	Schema   quickwit.LogSchema
+	Ingester quickwit.IngesterConfig
+}
+
+// Hook ships log entries to a Quickwit index.
+// Entries are buffered by an Ingester so firing the hook never blocks on the network.
+type Hook struct {
+	levels   []logrus.Level
+	schema   quickwit.LogSchema
+	ingester *quickwit.Ingester
+}
+
+func NewHook(c quickwit.Client, indexID string, cfg HookConfig) *Hook {
+	levels := cfg.Levels
+	if len(levels) == 0 {
+		levels = logrus.AllLevels
+	}
+
+	return &Hook{
+		levels:   levels,
+		schema:   cfg.Schema,
+		ingester: quickwit.NewIngester(c, indexID, cfg.Ingester),
+	}
+}
+
+func (h *Hook) Levels() []logrus.Level {
+	return h.levels
+}
+
+// Fire converts the entry to a document and buffers it.
+// Entries dropped because the buffer is full are only reported through Stats.
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	fields := make(map[string]any, len(entry.Data))
+	for k, v := range entry.Data {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		fields[k] = v
+	}
+
+	var source *quickwit.LogSource
+	if entry.HasCaller() {
+		source = &quickwit.LogSource{
+			Function: entry.Caller.Function,
+			File:     entry.Caller.File,
+			Line:     entry.Caller.Line,
+		}
+	}
+
+	doc := h.schema.Document(entry.Time, entry.Level.String(), entry.Message, fields, source)
+
+	err := h.ingester.Add(doc)
+	if errors.Is(err, quickwit.ErrIngesterFull) {
+		return nil
+	}
+
+	return err
+}
+
+func (h *Hook) Flush(ctx context.Context) error {
+	return h.ingester.Flush(ctx)
+}
+
+// Close flushes the remaining entries, the hook must not be fired afterwards
+func (h *Hook) Close(ctx context.Context) error {
+	return h.ingester.Close(ctx)
+}
+
+func (h *Hook) Stats() quickwit.IngesterStats {
+	return h.ingester.Stats()
+}
@@ -0,0 +1,48 @@
+// Package qwlogrus integrates logrus with the Quickwit client: a logger adapter and a hook shipping
+// entries to a Quickwit index. It is a separate package so the client does not depend on logrus.
+package qwlogrus
+
+import (
+	"fmt"
+
+	quickwit "github.com/CleverCloud/quickwit-go"
+	"github.com/sirupsen/logrus"
+)
+
+type logger struct {
+	log logrus.FieldLogger
+}
+
+// NewLogger adapts a logrus logger for quickwit.WithLogger, key-value pairs become logrus fields
+func NewLogger(l logrus.FieldLogger) quickwit.Logger {
+	return &logger{log: l}
+}
+
+func (l *logger) Debug(msg string, keysAndValues ...any) {
+	l.log.WithFields(fields(keysAndValues)).Debug(msg)
+}
+
+func (l *logger) Info(msg string, keysAndValues ...any) {
+	l.log.WithFields(fields(keysAndValues)).Info(msg)
+}
+
+func (l *logger) Warn(msg string, keysAndValues ...any) {
+	l.log.WithFields(fields(keysAndValues)).Warn(msg)
+}
+
+func (l *logger) Error(msg string, keysAndValues ...any) {
+	l.log.WithFields(fields(keysAndValues)).Error(msg)
+}
+
+func fields(keysAndValues []any) logrus.Fields {
+	f := make(logrus.Fields, len(keysAndValues)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprint(keysAndValues[i])
+		if i+1 == len(keysAndValues) {
+			f["!BADKEY"] = key
+			break
+		}
+		f[key] = keysAndValues[i+1]
+	}
+	return f
+}
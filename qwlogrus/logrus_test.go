@@ -0,0 +1,77 @@
+package qwlogrus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	quickwit "github.com/CleverCloud/quickwit-go"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger(t *testing.T) {
+	l, hook := test.NewNullLogger()
+	l.SetLevel(logrus.DebugLevel)
+	log := NewLogger(l)
+
+	log.Debug("debug", "index", "logs", "docs", 3)
+	log.Info("info")
+	log.Warn("warn", "odd")
+	log.Error("error", "error", errors.New("boom"))
+
+	entries := hook.AllEntries()
+	require.Len(t, entries, 4)
+
+	assert.Equal(t, logrus.DebugLevel, entries[0].Level)
+	assert.Equal(t, logrus.Fields{"index": "logs", "docs": 3}, entries[0].Data)
+	assert.Equal(t, logrus.InfoLevel, entries[1].Level)
+	assert.Equal(t, logrus.WarnLevel, entries[2].Level)
+	assert.Equal(t, logrus.Fields{"!BADKEY": "odd"}, entries[2].Data)
+	assert.Equal(t, logrus.ErrorLevel, entries[3].Level)
+	assert.Equal(t, "error", entries[3].Message)
+}
+
+// ingestClient records the ingested documents
+type ingestClient struct {
+	quickwit.Client
+	docs []string
+}
+
+func (c *ingestClient) Ingest(_ context.Context, _ string, docs io.Reader) (*quickwit.IngestResponse, error) {
+	b, _ := io.ReadAll(docs)
+	c.docs = append(c.docs, strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")...)
+	return &quickwit.IngestResponse{}, nil
+}
+
+func TestHook(t *testing.T) {
+	c := &ingestClient{}
+	hook := NewHook(c, "logs", HookConfig{Levels: []logrus.Level{logrus.InfoLevel}, Ingester: quickwit.IngesterConfig{FlushInterval: time.Hour}})
+
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	l.AddHook(hook)
+
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	l.WithTime(ts).WithField("user", "alice").WithError(errors.New("denied")).Info("login refused")
+	l.Warn("not fired")
+
+	require.NoError(t, hook.Close(context.Background()))
+	require.Len(t, c.docs, 1)
+
+	doc := map[string]any{}
+	require.NoError(t, json.Unmarshal([]byte(c.docs[0]), &doc))
+	assert.Equal(t, map[string]any{
+		"timestamp": "2026-01-02T03:04:05Z",
+		"level":     "info",
+		"message":   "login refused",
+		"fields":    map[string]any{"user": "alice", "error": "denied"},
+	}, doc)
+	assert.Equal(t, uint64(1), hook.Stats().Sent)
+}
@@ -0,0 +1,279 @@
+package quickwit
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimit bounds the request rate with a token bucket and the number of concurrent requests.
+// Zero values disable the corresponding limit.
+type RateLimit struct {
+	RequestsPerSecond float64
+	// Bucket size, defaults to RequestsPerSecond rounded up
+	Burst int
+	// Requests sent and not fully read yet
+	MaxInFlight int
+}
+
+type RateLimiterConfig struct {
+	// Applied to every request
+	Global RateLimit
+	// Applied on top of the global limit to the requests of an operation class
+	PerClass map[OperationClass]RateLimit
+	// Called when a request had to wait, with the time spent waiting
+	OnWait func(class OperationClass, waited time.Duration)
+}
+
+// RateLimitStats are the wait-time metrics of an operation class
+type RateLimitStats struct {
+	Requests  uint64
+	Throttled uint64 // requests which had to wait
+	TotalWait time.Duration
+	MaxWait   time.Duration
+	InFlight  int
+}
+
+// RateLimiter throttles requests before they are sent, waiting respects the request context
+type RateLimiter struct {
+	cfg    RateLimiterConfig
+	global *limit
+	class  map[OperationClass]*limit
+
+	mu    sync.Mutex
+	stats map[OperationClass]*RateLimitStats
+}
+
+func NewRateLimiter(cfg RateLimiterConfig) *RateLimiter {
+	l := &RateLimiter{
+		cfg:    cfg,
+		global: newLimit(cfg.Global),
+		class:  map[OperationClass]*limit{},
+		stats:  map[OperationClass]*RateLimitStats{},
+	}
+
+	for class, rl := range cfg.PerClass {
+		l.class[class] = newLimit(rl)
+	}
+
+	return l
+}
+
+// Stats returns the metrics of each operation class seen so far
+func (l *RateLimiter) Stats() map[OperationClass]RateLimitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := make(map[OperationClass]RateLimitStats, len(l.stats))
+	for class, s := range l.stats {
+		stats[class] = *s
+	}
+	return stats
+}
+
+// Middleware waits for the global and operation class limits before sending each request
+func (l *RateLimiter) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			op, _ := OperationFromContext(ctx)
+			limits := []*limit{l.global, l.class[op.Class]}
+
+			// tokens are given back when the request is not sent
+			var reserved []*limit
+			refund := func() {
+				for _, lim := range reserved {
+					lim.cancel()
+				}
+			}
+
+			start := time.Now()
+			for _, lim := range limits {
+				if err := lim.waitToken(ctx); err != nil {
+					refund()
+					return nil, err
+				}
+				reserved = append(reserved, lim)
+			}
+
+			var acquired []*limit
+			release := func() {
+				for _, lim := range acquired {
+					lim.release()
+				}
+				l.inFlight(op.Class, -1)
+			}
+			for _, lim := range limits {
+				if err := lim.acquire(ctx); err != nil {
+					for _, a := range acquired {
+						a.release()
+					}
+					refund()
+					return nil, err
+				}
+				acquired = append(acquired, lim)
+			}
+
+			l.record(op.Class, time.Since(start))
+
+			res, err := next.RoundTrip(req)
+			if err != nil {
+				release()
+				return nil, err
+			}
+
+			res.Body = &releaseOnClose{ReadCloser: res.Body, release: release}
+			return res, nil
+		})
+	}
+}
+
+func (l *RateLimiter) record(class OperationClass, waited time.Duration) {
+	// a few microseconds are spent even when no limit applies
+	throttled := waited > time.Millisecond
+
+	l.mu.Lock()
+	s, ok := l.stats[class]
+	if !ok {
+		s = &RateLimitStats{}
+		l.stats[class] = s
+	}
+	s.Requests++
+	s.InFlight++
+	if throttled {
+		s.Throttled++
+		s.TotalWait += waited
+		s.MaxWait = max(s.MaxWait, waited)
+	}
+	l.mu.Unlock()
+
+	if throttled && l.cfg.OnWait != nil {
+		l.cfg.OnWait(class, waited)
+	}
+}
+
+func (l *RateLimiter) inFlight(class OperationClass, delta int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if s, ok := l.stats[class]; ok {
+		s.InFlight += delta
+	}
+}
+
+// limit is a token bucket coupled with a semaphore, a nil limit never waits
+type limit struct {
+	sem chan struct{}
+
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newLimit(rl RateLimit) *limit {
+	if rl.RequestsPerSecond <= 0 && rl.MaxInFlight <= 0 {
+		return nil
+	}
+
+	lim := &limit{rate: rl.RequestsPerSecond, last: time.Now()}
+	if rl.RequestsPerSecond > 0 {
+		lim.burst = float64(rl.Burst)
+		if rl.Burst <= 0 {
+			lim.burst = math.Ceil(rl.RequestsPerSecond)
+		}
+		lim.tokens = lim.burst
+	}
+	if rl.MaxInFlight > 0 {
+		lim.sem = make(chan struct{}, rl.MaxInFlight)
+	}
+
+	return lim
+}
+
+func (lim *limit) waitToken(ctx context.Context) error {
+	if lim == nil || lim.rate <= 0 {
+		return nil
+	}
+
+	d := lim.reserve()
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		lim.cancel()
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token and returns how long to wait until it is actually available
+func (lim *limit) reserve() time.Duration {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	now := time.Now()
+	lim.tokens = min(lim.burst, lim.tokens+now.Sub(lim.last).Seconds()*lim.rate)
+	lim.last = now
+	lim.tokens--
+
+	if lim.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-lim.tokens / lim.rate * float64(time.Second))
+}
+
+// cancel gives back a token reserved by a request which stopped waiting
+func (lim *limit) cancel() {
+	if lim == nil || lim.rate <= 0 {
+		return
+	}
+
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	lim.tokens = min(lim.burst, lim.tokens+1)
+}
+
+func (lim *limit) acquire(ctx context.Context) error {
+	if lim == nil || lim.sem == nil {
+		return nil
+	}
+
+	select {
+	case lim.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (lim *limit) release() {
+	if lim == nil || lim.sem == nil {
+		return
+	}
+	<-lim.sem
+}
+
+// releaseOnClose keeps the in-flight slot until the response body is closed
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
@@ -0,0 +1,102 @@
+package quickwit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	ok := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, `{}`), nil
+	})
+	send := func(t *testing.T, rt http.RoundTripper, ctx context.Context, op string) (*http.Response, error) {
+		ctx = withOperation(ctx, Operation{Name: op, Class: operationClass(op)})
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://quickwit:7280/api/v1/indexes", nil)
+		require.NoError(t, err)
+		return rt.RoundTrip(req)
+	}
+
+	t.Run("Token Bucket", func(t *testing.T) {
+		var waits []time.Duration
+		limiter := NewRateLimiter(RateLimiterConfig{
+			Global: RateLimit{RequestsPerSecond: 20, Burst: 2},
+			OnWait: func(class OperationClass, waited time.Duration) { waits = append(waits, waited) },
+		})
+		rt := limiter.Middleware()(ok)
+
+		start := time.Now()
+		for range 3 {
+			res, err := send(t, rt, context.Background(), OpSearch)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond, "the third request waits for a token")
+
+		stats := limiter.Stats()[ClassSearch]
+		assert.Equal(t, uint64(3), stats.Requests)
+		assert.Equal(t, uint64(1), stats.Throttled)
+		assert.Zero(t, stats.InFlight)
+		assert.Len(t, waits, 1)
+	})
+
+	t.Run("Global Token Refund", func(t *testing.T) {
+		limiter := NewRateLimiter(RateLimiterConfig{
+			Global: RateLimit{RequestsPerSecond: 0.01, Burst: 2},
+			PerClass: map[OperationClass]RateLimit{
+				ClassSearch: {RequestsPerSecond: 0.01, Burst: 1},
+			},
+		})
+		rt := limiter.Middleware()(ok)
+
+		res, err := send(t, rt, context.Background(), OpSearch)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		// the search bucket is empty, the request gives up while waiting for it
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err = send(t, rt, ctx, OpSearch)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		// the global token taken by the cancelled request is available again
+		ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err = send(t, rt, ctx, OpListIndexes)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), limiter.Stats()[ClassSearch].Requests)
+		assert.Zero(t, limiter.Stats()[ClassAdmin].Throttled)
+	})
+
+	t.Run("Max In Flight", func(t *testing.T) {
+		limiter := NewRateLimiter(RateLimiterConfig{
+			PerClass: map[OperationClass]RateLimit{ClassIngest: {MaxInFlight: 1}},
+		})
+		rt := limiter.Middleware()(ok)
+
+		res, err := send(t, rt, context.Background(), OpIngest)
+		require.NoError(t, err)
+		assert.Equal(t, 1, limiter.Stats()[ClassIngest].InFlight)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err = send(t, rt, ctx, OpIngest)
+		assert.ErrorIs(t, err, context.DeadlineExceeded, "the slot is held until the body is closed")
+
+		// other classes are not limited
+		other, err := send(t, rt, context.Background(), OpSearch)
+		require.NoError(t, err)
+		require.NoError(t, other.Body.Close())
+
+		require.NoError(t, res.Body.Close())
+		assert.Zero(t, limiter.Stats()[ClassIngest].InFlight)
+
+		res, err = send(t, rt, context.Background(), OpIngest)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	})
+}
@@ -0,0 +1,603 @@
+package quickwit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrRecreateRequired = errors.New("quickwit: index change requires recreating the index")
+	ErrPruneProtection  = errors.New("quickwit: prune refused")
+	ErrInvalidSpec      = errors.New("quickwit: invalid index spec")
+)
+
+// IndexSpec is the desired state of an index and its sources.
+// Specs decoded from JSON or YAML apply every field present in the index_config document, zero values included.
+// Specs built in Go cannot tell an unset field from a zero one: their zero values keep the cluster value.
+type IndexSpec struct {
+	Config  IndexConfig    `json:"index_config" yaml:"index_config"`
+	Sources []SourceConfig `json:"sources,omitempty" yaml:"sources,omitempty"`
+
+	// index_config document the spec was decoded from, nil for specs built in Go
+	doc map[string]any
+}
+
+func (s *IndexSpec) UnmarshalJSON(b []byte) error {
+	type plain IndexSpec
+	raw := struct {
+		Config map[string]any `json:"index_config"`
+	}{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, (*plain)(s)); err != nil {
+		return err
+	}
+	s.doc = raw.Config
+	return nil
+}
+
+func (s *IndexSpec) UnmarshalYAML(node *yaml.Node) error {
+	type plain IndexSpec
+	raw := struct {
+		Config map[string]any `yaml:"index_config"`
+	}{}
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+	if err := node.Decode((*plain)(s)); err != nil {
+		return err
+	}
+	s.doc = raw.Config
+	return nil
+}
+
+// merge returns the current config overridden by the fields set by the spec, which is what an update must send:
+// UpdateIndex replaces the whole config. The current config is the raw document listed from the cluster,
+// so the settings IndexConfig does not model are kept.
+func (s *IndexSpec) merge(current map[string]any) (map[string]any, error) {
+	desired, err := toJSONValue(s.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc any
+	if s.doc != nil {
+		// YAML values to their JSON form
+		if doc, err = toJSONValue(s.doc); err != nil {
+			return nil, err
+		}
+	} else {
+		doc = withoutZeros(desired)
+	}
+
+	merged, _ := overlay(desired, doc, current).(map[string]any)
+	return merged, nil
+}
+
+// listedIndex is an index listed by Apply along with its raw config
+type listedIndex struct {
+	Index
+	document map[string]any
+}
+
+func (i *listedIndex) UnmarshalJSON(b []byte) error {
+	raw := struct {
+		Config map[string]any `json:"index_config"`
+	}{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &i.Index); err != nil {
+		return err
+	}
+	i.document = raw.Config
+	return nil
+}
+
+type ApplyActionType string
+
+const (
+	ActionCreateIndex  ApplyActionType = "create_index"
+	ActionUpdateIndex  ApplyActionType = "update_index"
+	ActionDeleteIndex  ApplyActionType = "delete_index"
+	ActionAddSource    ApplyActionType = "add_source"
+	ActionDeleteSource ApplyActionType = "delete_source"
+)
+
+type ApplyStatus string
+
+const (
+	StatusPending ApplyStatus = "pending"
+	StatusDone    ApplyStatus = "done"
+	StatusFailed  ApplyStatus = "failed"
+	// Not executable, e.g. an update which requires recreating the index
+	StatusBlocked ApplyStatus = "blocked"
+)
+
+type ApplyAction struct {
+	Type     ApplyActionType `json:"type"`
+	IndexID  string          `json:"index_id"`
+	SourceID string          `json:"source_id,omitempty"`
+	// Config changes of an update
+	Changes IndexConfigDiff `json:"changes,omitempty"`
+	Status  ApplyStatus     `json:"status"`
+	Error   string          `json:"error,omitempty"`
+
+	index *IndexConfig
+	// merged config document of an update
+	document map[string]any
+	source   *SourceConfig
+}
+
+func (a ApplyAction) String() string {
+	s := fmt.Sprintf("%s %s", a.Type, a.IndexID)
+	if a.SourceID != "" {
+		s += "/" + a.SourceID
+	}
+	for _, c := range a.Changes {
+		s += "\n  " + c.String()
+	}
+	if a.Error != "" {
+		s += "\n  error: " + a.Error
+	}
+	return s
+}
+
+// ApplyReport lists the planned actions and their outcome, it can be serialized to JSON
+type ApplyReport struct {
+	DryRun  bool          `json:"dry_run"`
+	Actions []ApplyAction `json:"actions"`
+}
+
+// Done tells whether every action was executed
+func (r *ApplyReport) Done() bool {
+	for _, a := range r.Actions {
+		if a.Status != StatusDone {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *ApplyReport) String() string {
+	lines := make([]string, len(r.Actions))
+	for i, a := range r.Actions {
+		lines[i] = fmt.Sprintf("[%s] %s", a.Status, a)
+	}
+	return strings.Join(lines, "\n")
+}
+
+type ApplyOptions struct {
+	// Only plan the actions
+	DryRun bool
+	// Delete the sources of the desired indexes which are not desired, and the indexes selected by Managed
+	// which are not desired. Built-in ingest sources are never deleted
+	Prune bool
+	// Indexes owned by the desired state, e.g. a tenant prefix. Indexes are never deleted when nil
+	Managed func(indexID string) bool
+	// Maximum number of deletions, the plan is refused above it. No limit when 0
+	MaxPrune int
+}
+
+// Apply converges the cluster towards the desired indexes and sources. Actions run in order and stop at the
+// first failure, Apply can be called again to resume: the plan is computed from the cluster state, so the
+// actions already done are not planned again.
+// A blocked update skips the other actions of its index, the actions of the other indexes still run.
+// Updates send the current config overridden by the fields set in the spec, see IndexSpec.
+// Sources cannot be updated: a changed source is deleted then added again under the same ID. The replacement
+// is not atomic, the source and its checkpoint are gone in between, and until the next Apply if the add fails.
+func (c *client) Apply(ctx context.Context, desired []IndexSpec, opts ApplyOptions) (*ApplyReport, error) {
+	req, err := c.newRequest(ctx, Operation{Name: OpListIndexes}, http.MethodGet, "/api/v1/indexes", nil)
+	if err != nil {
+		return nil, err
+	}
+	current, err := GetList[listedIndex](c.doer, c.log, req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list indexes: %w", err)
+	}
+
+	actions, err := planApply(desired, current, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ApplyReport{DryRun: opts.DryRun, Actions: actions}
+	if opts.DryRun {
+		return report, nil
+	}
+
+	errs := []error{}
+	blocked := map[string]bool{}
+	for i := range report.Actions {
+		a := &report.Actions[i]
+		if blocked[a.IndexID] {
+			continue
+		}
+		if a.Status == StatusBlocked {
+			blocked[a.IndexID] = true
+			errs = append(errs, fmt.Errorf("%s %s: %w", a.Type, a.IndexID, ErrRecreateRequired))
+			continue
+		}
+
+		if err := c.applyAction(ctx, a); err != nil {
+			a.Status, a.Error = StatusFailed, err.Error()
+			errs = append(errs, fmt.Errorf("%s %s: %w", a.Type, a.IndexID, err))
+			break
+		}
+		a.Status = StatusDone
+	}
+
+	return report, errors.Join(errs...)
+}
+
+func (c *client) applyAction(ctx context.Context, a *ApplyAction) error {
+	var err error
+	switch a.Type {
+	case ActionCreateIndex:
+		_, err = c.CreateIndex(ctx, *a.index)
+	case ActionUpdateIndex:
+		_, err = c.updateIndexDocument(ctx, a.IndexID, a.document)
+	case ActionDeleteIndex:
+		err = c.DeleteIndex(ctx, a.IndexID)
+	case ActionAddSource:
+		_, err = c.CreateSource(ctx, a.IndexID, *a.source)
+	case ActionDeleteSource:
+		err = c.DeleteSource(ctx, a.IndexID, a.SourceID)
+	}
+	return err
+}
+
+// planApply returns the actions converging current towards desired: index creations and updates first,
+// then source changes, then deletions
+func planApply(desired []IndexSpec, current []listedIndex, opts ApplyOptions) ([]ApplyAction, error) {
+	if opts.Prune && len(desired) == 0 {
+		return nil, fmt.Errorf("%w: empty desired state", ErrPruneProtection)
+	}
+
+	existing := map[string]listedIndex{}
+	for _, idx := range current {
+		existing[idx.Config.ID] = idx
+	}
+
+	wanted := map[string]bool{}
+	actions := []ApplyAction{}
+	deletions := []ApplyAction{}
+	for i := range desired {
+		spec := &desired[i]
+		id := spec.Config.ID
+		if id == "" {
+			return nil, fmt.Errorf("%w: desired index #%d has no index_id", ErrInvalidSpec, i)
+		}
+		if wanted[id] {
+			return nil, fmt.Errorf("%w: index %s is desired twice", ErrInvalidSpec, id)
+		}
+		wanted[id] = true
+
+		idx, ok := existing[id]
+		if !ok {
+			actions = append(actions, ApplyAction{Type: ActionCreateIndex, IndexID: id, Status: StatusPending, index: &spec.Config})
+		} else {
+			merged, err := spec.merge(idx.document)
+			if err != nil {
+				return nil, fmt.Errorf("%w: index %s: %w", ErrInvalidSpec, id, err)
+			}
+			changes, err := diffIndexDocuments(idx.document, merged)
+			if err != nil {
+				return nil, fmt.Errorf("%w: index %s: %w", ErrInvalidSpec, id, err)
+			}
+			if len(changes) > 0 {
+				a := ApplyAction{Type: ActionUpdateIndex, IndexID: id, Changes: changes, Status: StatusPending, document: merged}
+				if changes.RequiresRecreate() {
+					a.Status, a.Error = StatusBlocked, ErrRecreateRequired.Error()
+				}
+				actions = append(actions, a)
+			}
+		}
+
+		sources := map[string]Source{}
+		for _, src := range idx.Sources {
+			sources[src.ID] = src
+		}
+		wantedSources := map[string]bool{}
+		for j := range spec.Sources {
+			src := &spec.Sources[j]
+			if wantedSources[src.ID] {
+				return nil, fmt.Errorf("%w: source %s/%s is desired twice", ErrInvalidSpec, id, src.ID)
+			}
+			wantedSources[src.ID] = true
+
+			cur, ok := sources[src.ID]
+			if ok && sourceMatches(cur, *src) {
+				continue
+			}
+			// sources cannot be updated, they are replaced
+			if ok {
+				actions = append(actions, ApplyAction{Type: ActionDeleteSource, IndexID: id, SourceID: src.ID, Status: StatusPending})
+			}
+			actions = append(actions, ApplyAction{Type: ActionAddSource, IndexID: id, SourceID: src.ID, Status: StatusPending, source: src})
+		}
+
+		if opts.Prune {
+			for _, src := range idx.Sources {
+				if !wantedSources[src.ID] && !strings.HasPrefix(src.ID, "_ingest") {
+					deletions = append(deletions, ApplyAction{Type: ActionDeleteSource, IndexID: id, SourceID: src.ID, Status: StatusPending})
+				}
+			}
+		}
+	}
+
+	if opts.Prune && opts.Managed != nil {
+		ids := []string{}
+		for id := range existing {
+			if !wanted[id] && opts.Managed(id) {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			deletions = append(deletions, ApplyAction{Type: ActionDeleteIndex, IndexID: id, Status: StatusPending})
+		}
+	}
+
+	if opts.MaxPrune > 0 && len(deletions) > opts.MaxPrune {
+		return nil, fmt.Errorf("%w: %d deletions planned, at most %d allowed", ErrPruneProtection, len(deletions), opts.MaxPrune)
+	}
+
+	return append(actions, deletions...), nil
+}
+
+// toJSONValue converts v to its generic JSON form: maps, slices and scalars
+func toJSONValue(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	return value, json.Unmarshal(b, &value)
+}
+
+func fromJSONValue(value any, v any) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// withoutZeros drops the zero values of the objects, it stands for the document of a spec built in Go
+func withoutZeros(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := map[string]any{}
+		for k, e := range v {
+			if e = withoutZeros(e); e != nil {
+				m[k] = e
+			}
+		}
+		if len(m) == 0 {
+			return nil
+		}
+		return m
+	case []any:
+		if len(v) == 0 {
+			return nil
+		}
+		// list entries are matched by position with the desired list, none is dropped
+		l := make([]any, len(v))
+		for i, e := range v {
+			l[i] = withoutZeros(e)
+		}
+		return l
+	case string, bool, float64:
+		if v == reflect.Zero(reflect.TypeOf(v)).Interface() {
+			return nil
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// overlay returns the desired value where the document sets it and the current one elsewhere.
+// Objects are merged key by key, list entries named like field mappings are merged with the current entry of the same name.
+func overlay(desired, doc, current any) any {
+	switch d := desired.(type) {
+	case map[string]any:
+		docMap, _ := doc.(map[string]any)
+		curMap, _ := current.(map[string]any)
+
+		merged := map[string]any{}
+		for k, v := range curMap {
+			merged[k] = v
+		}
+		for k, docValue := range docMap {
+			if v, ok := d[k]; ok {
+				merged[k] = overlay(v, docValue, curMap[k])
+			} else {
+				// omitted from the desired JSON: a zero value, or a setting IndexConfig does not model
+				merged[k] = overlay(docValue, docValue, curMap[k])
+			}
+		}
+		return merged
+	case []any:
+		docList, _ := doc.([]any)
+		curList, _ := current.([]any)
+
+		named := map[string]any{}
+		for _, e := range curList {
+			if name := entryName(e); name != "" {
+				named[name] = e
+			}
+		}
+
+		merged := make([]any, len(d))
+		for i, v := range d {
+			var docValue any
+			if i < len(docList) {
+				docValue = docList[i]
+			}
+			merged[i] = overlay(v, docValue, named[entryName(v)])
+		}
+		return merged
+	default:
+		return desired
+	}
+}
+
+func entryName(v any) string {
+	m, _ := v.(map[string]any)
+	name, _ := m["name"].(string)
+	return name
+}
+
+// diffIndexDocuments lists the changes between two raw configs: the ones of DiffIndexConfig, then the changes of
+// the settings IndexConfig does not model
+func diffIndexDocuments(current, merged map[string]any) (IndexConfigDiff, error) {
+	a, b := IndexConfig{}, IndexConfig{}
+	if err := fromJSONValue(current, &a); err != nil {
+		return nil, err
+	}
+	if err := fromJSONValue(merged, &b); err != nil {
+		return nil, err
+	}
+
+	modeled := DiffIndexConfig(a, b)
+	diff := append(IndexConfigDiff{}, modeled...)
+	diffDocuments("", current, merged, func(path string, from, to any) {
+		if path == "version" {
+			return
+		}
+		for _, c := range modeled {
+			if withinPath(path, c.Path) || withinPath(c.Path, path) {
+				return
+			}
+		}
+		diff = append(diff, IndexConfigChange{Path: path, From: from, To: to, Applicability: documentApplicability(path)})
+	})
+	return diff, nil
+}
+
+// diffDocuments walks raw configs and reports the leaves which differ, named list entries are matched by name
+func diffDocuments(path string, a, b any, report func(path string, from, to any)) {
+	if reflect.DeepEqual(a, b) {
+		return
+	}
+
+	switch a := a.(type) {
+	case map[string]any:
+		if b, ok := b.(map[string]any); ok {
+			keys := []string{}
+			for k := range a {
+				keys = append(keys, k)
+			}
+			for k := range b {
+				if _, ok := a[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				p := k
+				if path != "" {
+					p = path + "." + k
+				}
+				diffDocuments(p, a[k], b[k], report)
+			}
+			return
+		}
+	case []any:
+		if b, ok := b.([]any); ok && namedEntries(a) && namedEntries(b) {
+			named := map[string]any{}
+			for _, e := range b {
+				named[entryName(e)] = e
+			}
+			for _, e := range a {
+				diffDocuments(fmt.Sprintf("%s[%s]", path, entryName(e)), e, named[entryName(e)], report)
+				delete(named, entryName(e))
+			}
+			for _, e := range b {
+				if _, ok := named[entryName(e)]; ok {
+					report(fmt.Sprintf("%s[%s]", path, entryName(e)), nil, e)
+				}
+			}
+			return
+		}
+	}
+
+	report(path, a, b)
+}
+
+func namedEntries(l []any) bool {
+	for _, e := range l {
+		if entryName(e) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// withinPath tells whether path is prefix or one of its fields
+func withinPath(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+".") || strings.HasPrefix(path, prefix+"[")
+}
+
+// documentApplicability classifies the changes DiffIndexConfig does not know by their section
+func documentApplicability(path string) ChangeApplicability {
+	section, _, _ := strings.Cut(path, ".")
+	section, _, _ = strings.Cut(section, "[")
+	switch section {
+	case "doc_mapping":
+		return ChangeNewSplits
+	case "indexing_settings", "search_settings", "retention":
+		return ChangeLive
+	default:
+		return ChangeRecreate
+	}
+}
+
+// sourceMatches compares the desired source with the current one, params and transform through their JSON form
+func sourceMatches(cur Source, desired SourceConfig) bool {
+	if cur.SourceType != desired.Type {
+		return false
+	}
+	if desired.PipelineCount > 0 && cur.NumPipelines != desired.PipelineCount {
+		return false
+	}
+
+	inputFormat := func(format string) string {
+		if format == "" {
+			return DefaultSourceInputFormat
+		}
+		return format
+	}
+	if inputFormat(cur.InputFormat) != inputFormat(desired.InputFormat) {
+		return false
+	}
+
+	normalize := func(m map[string]any) any {
+		if len(m) == 0 {
+			return nil
+		}
+		var v any
+		_ = json.Unmarshal(MustMarshall(m).Bytes(), &v)
+		return v
+	}
+	// a desired source without transform does not match a current one with a transform
+	if !reflect.DeepEqual(normalize(cur.Transform), normalize(desired.Transform)) {
+		return false
+	}
+	if desired.Params == nil {
+		return true
+	}
+	return reflect.DeepEqual(normalize(cur.Params), normalize(desired.Params))
+}
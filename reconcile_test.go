@@ -0,0 +1,329 @@
+package quickwit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// fakeIndexes serves the index and source endpoints from memory
+type fakeIndexes struct {
+	mu      sync.Mutex
+	indexes map[string]map[string]any
+	calls   []string
+	fail    string
+}
+
+func (f *fakeIndexes) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	call := r.Method + " " + r.URL.Path
+	if r.URL.Path == "/api/v1/version" {
+		_, _ = fmt.Fprint(w, `{"build": {"version": "0.9.0"}}`)
+		return
+	}
+	if r.Method != http.MethodGet {
+		f.calls = append(f.calls, call)
+	}
+	if call == f.fail {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprint(w, `{"message": "refused"}`)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/indexes"), "/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/indexes":
+		list := []map[string]any{}
+		for _, idx := range f.indexes {
+			list = append(list, idx)
+		}
+		_ = json.NewEncoder(w).Encode(list)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/indexes":
+		cfg := map[string]any{}
+		_ = json.NewDecoder(r.Body).Decode(&cfg)
+		f.indexes[cfg["index_id"].(string)] = map[string]any{"index_config": cfg, "sources": []any{}}
+		_, _ = fmt.Fprint(w, `{}`)
+	case r.Method == http.MethodPut && len(parts) == 2:
+		cfg := map[string]any{}
+		_ = json.NewDecoder(r.Body).Decode(&cfg)
+		f.indexes[parts[1]]["index_config"] = cfg
+		_, _ = fmt.Fprint(w, `{}`)
+	case r.Method == http.MethodDelete && len(parts) == 2:
+		delete(f.indexes, parts[1])
+		_, _ = fmt.Fprint(w, `[]`)
+	case r.Method == http.MethodPost && len(parts) == 3:
+		src := map[string]any{}
+		_ = json.NewDecoder(r.Body).Decode(&src)
+		f.indexes[parts[1]]["sources"] = append(f.indexes[parts[1]]["sources"].([]any), src)
+		_, _ = fmt.Fprint(w, `{}`)
+	case r.Method == http.MethodDelete && len(parts) == 4:
+		sources := []any{}
+		for _, src := range f.indexes[parts[1]]["sources"].([]any) {
+			if src.(map[string]any)["source_id"] != parts[3] {
+				sources = append(sources, src)
+			}
+		}
+		f.indexes[parts[1]]["sources"] = sources
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestApply(t *testing.T) {
+	fake := &fakeIndexes{indexes: map[string]map[string]any{
+		"tenant-a": {
+			"index_config": map[string]any{"index_id": "tenant-a", "index_uri": "s3://bucket/tenant-a", "doc_mapping": map[string]any{"mode": "dynamic"}},
+			"sources": []any{
+				map[string]any{"source_id": "_ingest-api-source", "source_type": "ingest-api"},
+				map[string]any{"source_id": "pulsar", "source_type": "pulsar", "num_pipelines": 1, "params": map[string]any{"topics": []any{"old"}}},
+				map[string]any{"source_id": "kafka", "source_type": "kafka"},
+			},
+		},
+		"tenant-old": {"index_config": map[string]any{"index_id": "tenant-old"}},
+		"other":      {"index_config": map[string]any{"index_id": "other"}},
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := New(WithEndpoint(srv.URL), WithLogger(NewNopLogger()))
+	ctx := context.Background()
+
+	desired := []IndexSpec{
+		{
+			Config:  IndexConfig{ID: "tenant-a", Retention: &IndexRetention{Period: "30 days", Schedule: "daily"}},
+			Sources: []SourceConfig{NewPulsarSourceConfig("pulsar", "pulsar://pulsar:6650", "", "logs")},
+		},
+		{Config: IndexConfig{ID: "tenant-b", DocMapping: DocMapping{Mode: "dynamic"}}},
+	}
+	opts := ApplyOptions{Prune: true, Managed: func(id string) bool { return strings.HasPrefix(id, "tenant-") }}
+
+	report, err := c.Apply(ctx, desired, ApplyOptions{DryRun: true, Prune: opts.Prune, Managed: opts.Managed})
+	require.NoError(t, err)
+	assert.Empty(t, fake.calls)
+	assert.Equal(t, `[pending] update_index tenant-a
+  retention: <nil> -> {30 days daily} (live)
+[pending] delete_source tenant-a/pulsar
+[pending] add_source tenant-a/pulsar
+[pending] create_index tenant-b
+[pending] delete_source tenant-a/kafka
+[pending] delete_index tenant-old`, report.String())
+
+	_, err = c.Apply(ctx, desired, ApplyOptions{Prune: true, Managed: opts.Managed, MaxPrune: 1})
+	assert.ErrorIs(t, err, ErrPruneProtection)
+	_, err = c.Apply(ctx, nil, opts)
+	assert.ErrorIs(t, err, ErrPruneProtection)
+
+	// the execution stops at the first failure and resumes on the next call
+	fake.fail = "POST /api/v1/indexes/tenant-a/sources"
+	report, err = c.Apply(ctx, desired, opts)
+	assert.Error(t, err)
+	assert.False(t, report.Done())
+	assert.Equal(t, []ApplyStatus{StatusDone, StatusDone, StatusFailed, StatusPending, StatusPending, StatusPending}, statuses(report))
+
+	fake.fail = ""
+	report, err = c.Apply(ctx, desired, opts)
+	require.NoError(t, err)
+	assert.True(t, report.Done())
+	assert.Equal(t, `[done] add_source tenant-a/pulsar
+[done] create_index tenant-b
+[done] delete_source tenant-a/kafka
+[done] delete_index tenant-old`, report.String())
+	assert.Contains(t, fake.indexes, "other")
+
+	// the update sent the whole config, not only the desired fields
+	cfg := fake.indexes["tenant-a"]["index_config"].(map[string]any)
+	assert.Equal(t, "s3://bucket/tenant-a", cfg["index_uri"])
+	assert.Equal(t, "dynamic", cfg["doc_mapping"].(map[string]any)["mode"])
+	assert.Equal(t, "30 days", cfg["retention"].(map[string]any)["period"])
+
+	// converged
+	report, err = c.Apply(ctx, desired, opts)
+	require.NoError(t, err)
+	assert.Empty(t, report.Actions)
+
+	// the timestamp field cannot be changed in place, the other actions of the index are skipped
+	desired[0].Config.DocMapping.TimestampField = "ts"
+	desired[0].Sources = append(desired[0].Sources, SourceConfig{ID: "kafka", Type: "kafka"})
+	desired[1].Config.Retention = &IndexRetention{Period: "7 days", Schedule: "daily"}
+	report, err = c.Apply(ctx, desired, opts)
+	assert.ErrorIs(t, err, ErrRecreateRequired)
+	assert.Equal(t, []ApplyStatus{StatusBlocked, StatusPending, StatusDone}, statuses(report))
+	assert.Equal(t, ActionAddSource, report.Actions[1].Type)
+	assert.Len(t, fake.indexes["tenant-a"]["sources"], 2)
+}
+
+func TestApplyDecodedSpec(t *testing.T) {
+	fake := &fakeIndexes{indexes: map[string]map[string]any{
+		"logs": {"index_config": map[string]any{
+			"index_id":    "logs",
+			"index_uri":   "s3://bucket/logs",
+			"doc_mapping": map[string]any{"mode": "dynamic", "store_source": true},
+		}},
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := New(WithEndpoint(srv.URL), WithLogger(NewNopLogger()))
+	ctx := context.Background()
+
+	// zero values of specs built in Go keep the cluster value
+	report, err := c.Apply(ctx, []IndexSpec{{Config: IndexConfig{ID: "logs"}}}, ApplyOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.Empty(t, report.Actions)
+
+	// decoded specs apply the fields they set, zero values included
+	yamlSpec := IndexSpec{}
+	require.NoError(t, yaml.Unmarshal([]byte("index_config:\n  index_id: logs\n  doc_mapping:\n    store_source: false\n"), &yamlSpec))
+	jsonSpec := IndexSpec{}
+	require.NoError(t, json.Unmarshal([]byte(`{"index_config": {"index_id": "logs", "doc_mapping": {"store_source": false}}}`), &jsonSpec))
+
+	// field mappings get the same defaults in both formats
+	fields := []IndexSpec{}
+	for _, field := range []struct {
+		unmarshal func([]byte, any) error
+		doc       string
+	}{
+		{yaml.Unmarshal, "index_config:\n  index_id: logs\n  doc_mapping:\n    field_mappings:\n      - name: body\n        type: text\n"},
+		{json.Unmarshal, `{"index_config": {"index_id": "logs", "doc_mapping": {"field_mappings": [{"name": "body", "type": "text"}]}}}`},
+	} {
+		spec := IndexSpec{}
+		require.NoError(t, field.unmarshal([]byte(field.doc), &spec))
+		fields = append(fields, spec)
+	}
+	assert.Equal(t, fields[0].Config, fields[1].Config)
+	assert.True(t, fields[1].Config.DocMapping.FieldMappings[0].Indexed)
+	assert.True(t, fields[1].Config.DocMapping.FieldMappings[0].Stored)
+
+	for _, spec := range []IndexSpec{yamlSpec, jsonSpec} {
+		report, err := c.Apply(ctx, []IndexSpec{spec}, ApplyOptions{DryRun: true})
+		require.NoError(t, err)
+		assert.Equal(t, `[pending] update_index logs
+  doc_mapping.store_source: true -> false (new_splits)`, report.String())
+	}
+
+	_, err = c.Apply(ctx, []IndexSpec{yamlSpec}, ApplyOptions{})
+	require.NoError(t, err)
+	cfg := fake.indexes["logs"]["index_config"].(map[string]any)
+	assert.Equal(t, "s3://bucket/logs", cfg["index_uri"])
+	assert.Equal(t, map[string]any{"mode": "dynamic", "store_source": false}, cfg["doc_mapping"])
+}
+
+func TestApplyUnmodeledSettings(t *testing.T) {
+	docMapping := map[string]any{
+		"mode":            "dynamic",
+		"doc_mapping_uid": "01HVKHQ3ZJ5C0XBRHR9ZZJE5K4",
+		"dynamic_mapping": map[string]any{"indexed": true, "stored": true, "tokenizer": "raw", "fast": true},
+		"field_mappings": []any{
+			map[string]any{"name": "resource", "type": "object", "field_mappings": []any{
+				map[string]any{"name": "service", "type": "text", "tokenizer": "raw", "indexed": true, "stored": true},
+			}},
+		},
+	}
+	config := func() map[string]any {
+		var cfg map[string]any
+		_ = json.Unmarshal(MustMarshall(map[string]any{
+			"version":         "0.9",
+			"index_id":        "logs",
+			"index_uri":       "s3://bucket/logs",
+			"doc_mapping":     docMapping,
+			"search_settings": map[string]any{"unmodeled_setting": 1},
+		}).Bytes(), &cfg)
+		return cfg
+	}
+	fake := &fakeIndexes{indexes: map[string]map[string]any{"logs": {"index_config": config()}}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := New(WithEndpoint(srv.URL), WithLogger(NewNopLogger()))
+	ctx := context.Background()
+
+	// only the retention is reported and changed, the rest of the cluster config is sent back as is
+	desired := []IndexSpec{{Config: IndexConfig{ID: "logs", Retention: &IndexRetention{Period: "30 days"}}}}
+	report, err := c.Apply(ctx, desired, ApplyOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, `[done] update_index logs
+  retention: <nil> -> {30 days } (live)`, report.String())
+
+	expected := config()
+	expected["retention"] = map[string]any{"period": "30 days"}
+	assert.Equal(t, expected, fake.indexes["logs"]["index_config"])
+
+	// changes of settings IndexConfig does not model are reported too
+	spec := IndexSpec{}
+	require.NoError(t, yaml.Unmarshal([]byte(`
+index_config:
+  index_id: logs
+  retention:
+    period: 30 days
+  doc_mapping:
+    dynamic_mapping:
+      tokenizer: default
+  search_settings:
+    unmodeled_setting: 2
+`), &spec))
+	report, err = c.Apply(ctx, []IndexSpec{spec}, ApplyOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, `[pending] update_index logs
+  doc_mapping.dynamic_mapping.tokenizer: raw -> default (new_splits)
+  search_settings.unmodeled_setting: 1 -> 2 (live)`, report.String())
+}
+
+func statuses(report *ApplyReport) []ApplyStatus {
+	s := []ApplyStatus{}
+	for _, a := range report.Actions {
+		s = append(s, a.Status)
+	}
+	return s
+}
+
+func TestSourceMatches(t *testing.T) {
+	current := Source{
+		ID:           "kafka",
+		SourceType:   "kafka",
+		NumPipelines: 2,
+		InputFormat:  "json",
+		Transform:    map[string]any{"script": ".message = upcase(.message)"},
+		Params:       map[string]any{"topic": "logs"},
+	}
+	desired := func(update func(src *SourceConfig)) SourceConfig {
+		src := SourceConfig{
+			ID:        "kafka",
+			Type:      "kafka",
+			Transform: map[string]any{"script": ".message = upcase(.message)"},
+			Params:    map[string]any{"topic": "logs"},
+		}
+		update(&src)
+		return src
+	}
+
+	for _, tt := range []struct {
+		name    string
+		desired SourceConfig
+		matches bool
+	}{
+		{name: "same", desired: desired(func(src *SourceConfig) {}), matches: true},
+		{name: "default input format", desired: desired(func(src *SourceConfig) { src.InputFormat = "json" }), matches: true},
+		{name: "params not desired", desired: desired(func(src *SourceConfig) { src.Params = nil }), matches: true},
+		{name: "type", desired: desired(func(src *SourceConfig) { src.Type = "pulsar" })},
+		{name: "pipelines", desired: desired(func(src *SourceConfig) { src.PipelineCount = 1 })},
+		{name: "input format", desired: desired(func(src *SourceConfig) { src.InputFormat = "plain_text" })},
+		{name: "transform", desired: desired(func(src *SourceConfig) { src.Transform["script"] = ".message = downcase(.message)" })},
+		{name: "transform removed", desired: desired(func(src *SourceConfig) { src.Transform = nil })},
+		{name: "params", desired: desired(func(src *SourceConfig) { src.Params["topic"] = "events" })},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.matches, sourceMatches(current, tt.desired))
+		})
+	}
+}
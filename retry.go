@@ -0,0 +1,220 @@
+package quickwit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultRetryMaxAttempts    = 3
+	DefaultRetryInitialBackoff = 100 * time.Millisecond
+	DefaultRetryMaxBackoff     = 2 * time.Second
+	DefaultRetryMultiplier     = 2
+	DefaultRetryJitter         = 0.5
+)
+
+type RetryPolicy struct {
+	// Total number of attempts, including the first one
+	MaxAttempts int
+	// Backoff before the second attempt, multiplied by Multiplier for each following attempt up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Fraction of each backoff which is randomized, between 0 and 1, defaults to 0.5
+	Jitter float64
+	// Disable the jitter, backoffs are exactly InitialBackoff * Multiplier^n
+	NoJitter bool
+	// Decides whether an attempt is retried, defaults to network errors and 429, 502, 503 or 504 responses
+	RetryOn func(res *http.Response, err error) bool
+	// Called after each attempt, from the goroutine making the call
+	OnAttempt func(RetryAttempt)
+}
+
+// RetryAttempt describes an attempt made by the retry middleware
+type RetryAttempt struct {
+	Operation  Operation
+	Method     string
+	URL        string
+	Attempt    int
+	StatusCode int
+	Err        error
+	Duration   time.Duration
+	// Wait before the next attempt, zero when the attempt is not retried
+	Backoff time.Duration
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = DefaultRetryInitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultRetryMultiplier
+	}
+	if p.NoJitter {
+		p.Jitter = 0
+	} else if p.Jitter <= 0 || p.Jitter > 1 {
+		p.Jitter = DefaultRetryJitter
+	}
+	if p.RetryOn == nil {
+		p.RetryOn = isRetryable
+	}
+	return p
+}
+
+// backoff returns the jittered wait after the given attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.InitialBackoff)
+	for i := 1; i < attempt && d < float64(p.MaxBackoff); i++ {
+		d *= p.Multiplier
+	}
+	d = min(d, float64(p.MaxBackoff))
+
+	return time.Duration(d * (1 - p.Jitter*rand.Float64()))
+}
+
+type retrySafeKey struct{}
+
+// MarkRetrySafe allows the retry middleware to retry non-idempotent requests (POST...) made with this context
+func MarkRetrySafe(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retrySafeKey{}, true)
+}
+
+func isRetrySafe(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return true
+	default:
+		safe, _ := req.Context().Value(retrySafeKey{}).(bool)
+		return safe
+	}
+}
+
+// RetryMiddleware retries failed requests with an exponential backoff.
+// GET, HEAD and DELETE requests are retried, other methods only when marked with MarkRetrySafe.
+// No attempt is made when the backoff would exceed the context deadline.
+func RetryMiddleware(policy RetryPolicy) Middleware {
+	policy = policy.withDefaults()
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if !isRetrySafe(req) || !canRewind(req) {
+				return next.RoundTrip(req)
+			}
+
+			ctx := req.Context()
+			op, _ := OperationFromContext(ctx)
+
+			for attempt := 1; ; attempt++ {
+				start := time.Now()
+				res, err := next.RoundTrip(req)
+
+				a := RetryAttempt{
+					Operation: op,
+					Method:    req.Method,
+					URL:       req.URL.String(),
+					Attempt:   attempt,
+					Err:       err,
+					Duration:  time.Since(start),
+				}
+				if res != nil {
+					a.StatusCode = res.StatusCode
+				}
+
+				retry := attempt < policy.MaxAttempts && ctx.Err() == nil && policy.RetryOn(res, err)
+				if retry {
+					a.Backoff = max(policy.backoff(attempt), retryAfter(res))
+					if deadline, ok := ctx.Deadline(); ok && time.Now().Add(a.Backoff).After(deadline) {
+						retry = false
+						a.Backoff = 0
+					}
+				}
+
+				if policy.OnAttempt != nil {
+					policy.OnAttempt(a)
+				}
+
+				if !retry {
+					return res, err
+				}
+				if res != nil {
+					_, _ = io.Copy(io.Discard, res.Body)
+					_ = res.Body.Close()
+				}
+
+				timer := time.NewTimer(a.Backoff)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return nil, ctx.Err()
+				case <-timer.C:
+				}
+
+				if req, err = rewindRequest(req); err != nil {
+					return nil, err
+				}
+			}
+		})
+	}
+}
+
+func isRetryable(res *http.Response, err error) bool {
+	if errors.Is(err, ErrCircuitOpen) || isInterceptorError(err) {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryAfter reads the Retry-After header, only the delay in seconds form is supported
+func retryAfter(res *http.Response) time.Duration {
+	if res == nil {
+		return 0
+	}
+
+	secs, err := strconv.Atoi(res.Header.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return 0
+	}
+
+	return time.Duration(secs) * time.Second
+}
+
+func canRewind(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewindRequest returns a copy of the request with a fresh body
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	r := req.Clone(req.Context())
+	r.Body = body
+
+	return r, nil
+}
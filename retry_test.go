@@ -0,0 +1,133 @@
+package quickwit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, NoJitter: true}.withDefaults()
+	assert.Equal(t, 0.0, p.Jitter)
+	assert.Equal(t, 100*time.Millisecond, p.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, p.backoff(2))
+	assert.Equal(t, 400*time.Millisecond, p.backoff(3))
+	assert.Equal(t, time.Second, p.backoff(5))
+
+	jittered := RetryPolicy{InitialBackoff: 100 * time.Millisecond}.withDefaults()
+	assert.Equal(t, DefaultRetryJitter, jittered.Jitter)
+	for range 10 {
+		d := jittered.backoff(1)
+		assert.True(t, d > 50*time.Millisecond && d <= 100*time.Millisecond, d)
+	}
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	// statuses are returned in order, the last one repeats
+	server := func(statuses ...int) (http.RoundTripper, *[]string) {
+		bodies := []string{}
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			body := ""
+			if req.Body != nil {
+				b, _ := io.ReadAll(req.Body)
+				body = string(b)
+			}
+			bodies = append(bodies, body)
+
+			status := statuses[min(len(bodies), len(statuses))-1]
+			res := jsonResponse(status, `{}`)
+			if status == http.StatusTooManyRequests {
+				res.Header.Set("Retry-After", "1")
+			}
+			return res, nil
+		}), &bodies
+	}
+
+	var attempts []RetryAttempt
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, NoJitter: true, OnAttempt: func(a RetryAttempt) { attempts = append(attempts, a) }}
+	newRequest := func(ctx context.Context, method, body string) *http.Request {
+		req, err := http.NewRequestWithContext(ctx, method, "http://quickwit/api/v1/indexes", strings.NewReader(body))
+		require.NoError(t, err)
+		return req
+	}
+
+	t.Run("Idempotent Requests", func(t *testing.T) {
+		attempts = nil
+		base, calls := server(http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK)
+		res, err := Chain(base, RetryMiddleware(policy)).RoundTrip(newRequest(context.Background(), http.MethodGet, ""))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, *calls, 3)
+
+		require.Len(t, attempts, 3)
+		assert.Equal(t, time.Millisecond, attempts[0].Backoff)
+		assert.Equal(t, 2*time.Millisecond, attempts[1].Backoff)
+		assert.Equal(t, time.Duration(0), attempts[2].Backoff)
+	})
+
+	t.Run("Attempts Exhausted", func(t *testing.T) {
+		base, calls := server(http.StatusBadGateway)
+		res, err := Chain(base, RetryMiddleware(policy)).RoundTrip(newRequest(context.Background(), http.MethodDelete, ""))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadGateway, res.StatusCode)
+		assert.Len(t, *calls, 3)
+	})
+
+	t.Run("Client Errors", func(t *testing.T) {
+		base, calls := server(http.StatusBadRequest)
+		_, err := Chain(base, RetryMiddleware(policy)).RoundTrip(newRequest(context.Background(), http.MethodGet, ""))
+		require.NoError(t, err)
+		assert.Len(t, *calls, 1)
+	})
+
+	t.Run("Non Idempotent Requests", func(t *testing.T) {
+		base, calls := server(http.StatusServiceUnavailable, http.StatusOK)
+		_, err := Chain(base, RetryMiddleware(policy)).RoundTrip(newRequest(context.Background(), http.MethodPost, `{"doc": 1}`))
+		require.NoError(t, err)
+		assert.Len(t, *calls, 1)
+
+		// marked requests are retried with their body rewound
+		base, calls = server(http.StatusServiceUnavailable, http.StatusOK)
+		res, err := Chain(base, RetryMiddleware(policy)).RoundTrip(newRequest(MarkRetrySafe(context.Background()), http.MethodPost, `{"doc": 1}`))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []string{`{"doc": 1}`, `{"doc": 1}`}, *calls)
+	})
+
+	t.Run("Retry After", func(t *testing.T) {
+		res := jsonResponse(http.StatusTooManyRequests, `{}`)
+		res.Header.Set("Retry-After", "2")
+		assert.Equal(t, 2*time.Second, retryAfter(res))
+		res.Header.Set("Retry-After", "Wed, 21 Oct 2026 07:28:00 GMT")
+		assert.Equal(t, time.Duration(0), retryAfter(res))
+
+		// the Retry-After delay exceeds the deadline, no attempt is made
+		attempts = nil
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		base, calls := server(http.StatusTooManyRequests)
+		res, err := Chain(base, RetryMiddleware(policy)).RoundTrip(newRequest(ctx, http.MethodGet, ""))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+		assert.Len(t, *calls, 1)
+		require.Len(t, attempts, 1)
+		assert.Equal(t, time.Duration(0), attempts[0].Backoff)
+	})
+
+	t.Run("Circuit Open", func(t *testing.T) {
+		calls := 0
+		base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls++
+			return nil, ErrCircuitOpen
+		})
+		_, err := Chain(base, RetryMiddleware(policy)).RoundTrip(newRequest(context.Background(), http.MethodGet, ""))
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+		assert.Equal(t, 1, calls)
+	})
+}
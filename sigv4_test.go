@@ -0,0 +1,101 @@
+package quickwit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Vectors from the AWS Signature Version 4 test suite
+func TestSigV4(t *testing.T) {
+	creds := StaticCredentialsProvider{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	signedAt := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		method        string
+		url           string
+		headers       map[string]string
+		body          string
+		authorization string
+	}{
+		{
+			name:          "get-vanilla",
+			method:        http.MethodGet,
+			url:           "https://example.amazonaws.com/",
+			authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:          "get-vanilla-query-order-key-case",
+			method:        http.MethodGet,
+			url:           "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:          "post-x-www-form-urlencoded",
+			method:        http.MethodPost,
+			url:           "https://example.amazonaws.com/",
+			headers:       map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			body:          "Param1=value1",
+			authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := NewSigV4Signer("us-east-1", "service", creds)
+			signer.now = func() time.Time { return signedAt }
+
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			if tt.body == "" {
+				req.Body, req.GetBody = http.NoBody, nil
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			require.NoError(t, signer.Sign(context.Background(), req))
+			assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+			assert.Equal(t, tt.authorization, req.Header.Get("Authorization"))
+		})
+	}
+
+	t.Run("streaming body", func(t *testing.T) {
+		signer := NewSigV4Signer("us-east-1", "service", creds)
+		signer.now = func() time.Time { return signedAt }
+
+		req, err := http.NewRequest(http.MethodPost, "https://example.amazonaws.com/", io.NopCloser(strings.NewReader("Param1=value1")))
+		require.NoError(t, err)
+		require.Nil(t, req.GetBody)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		require.NoError(t, signer.Sign(context.Background(), req))
+		assert.Equal(t, tests[2].authorization, req.Header.Get("Authorization"))
+
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "Param1=value1", string(body), "buffered body must still be sent")
+	})
+
+	t.Run("unsigned streaming body", func(t *testing.T) {
+		signer := NewSigV4Signer("us-east-1", "service", creds)
+		signer.UnsignedStreamingPayload = true
+
+		req, err := http.NewRequest(http.MethodPost, "https://example.amazonaws.com/api/v1/logs/ingest", io.NopCloser(strings.NewReader("{}\n")))
+		require.NoError(t, err)
+
+		require.NoError(t, signer.Sign(context.Background(), req))
+		assert.Equal(t, "UNSIGNED-PAYLOAD", req.Header.Get("X-Amz-Content-Sha256"))
+		assert.Contains(t, req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-content-sha256;x-amz-date")
+	})
+}
@@ -0,0 +1,172 @@
+package quickwit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+type SlogHandlerConfig struct {
+	// Minimum level handled, defaults to slog.LevelInfo
+	Level     slog.Leveler
+	AddSource bool
+	Schema    LogSchema
+	Ingester  IngesterConfig
+}
+
+// SlogHandler is a slog.Handler shipping records to a Quickwit index.
+// Records are buffered by an Ingester so logging never blocks on the network.
+type SlogHandler struct {
+	level     slog.Leveler
+	addSource bool
+	schema    LogSchema
+	ingester  *Ingester
+
+	// attributes bound with WithAttrs, along with the groups open at that time
+	bound  []boundAttrs
+	groups []string
+}
+
+type boundAttrs struct {
+	groups []string
+	attrs  []slog.Attr
+}
+
+func NewSlogHandler(c Client, indexID string, cfg SlogHandlerConfig) *SlogHandler {
+	level := cfg.Level
+	if level == nil {
+		level = slog.LevelInfo
+	}
+
+	return &SlogHandler{
+		level:     level,
+		addSource: cfg.AddSource,
+		schema:    cfg.Schema.withDefaults(),
+		ingester:  NewIngester(c, indexID, cfg.Ingester),
+	}
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := map[string]any{}
+	for _, b := range h.bound {
+		addAttrs(attrs, b.groups, b.attrs)
+	}
+
+	recordAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		recordAttrs = append(recordAttrs, a)
+		return true
+	})
+	addAttrs(attrs, h.groups, recordAttrs)
+
+	var source *LogSource
+	if h.addSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		source = &LogSource{Function: frame.Function, File: frame.File, Line: frame.Line}
+	}
+
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	doc := h.schema.Document(t, strings.ToLower(r.Level.String()), r.Message, attrs, source)
+
+	err := h.ingester.Add(doc)
+	if errors.Is(err, ErrIngesterFull) {
+		return nil
+	}
+
+	return err
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	h2 := *h
+	h2.bound = append(slices.Clip(h.bound), boundAttrs{groups: h.groups, attrs: attrs})
+	return &h2
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := *h
+	h2.groups = append(slices.Clip(h.groups), name)
+	return &h2
+}
+
+func (h *SlogHandler) Flush(ctx context.Context) error {
+	return h.ingester.Flush(ctx)
+}
+
+// Close flushes the remaining records, it is shared by every handler derived with WithAttrs or WithGroup
+func (h *SlogHandler) Close(ctx context.Context) error {
+	return h.ingester.Close(ctx)
+}
+
+func (h *SlogHandler) Stats() IngesterStats {
+	return h.ingester.Stats()
+}
+
+// addAttrs inserts attrs in m under the groups path, groups are only created when they hold a value
+func addAttrs(m map[string]any, groups []string, attrs []slog.Attr) {
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+		if a.Equal(slog.Attr{}) {
+			continue
+		}
+
+		if a.Value.Kind() == slog.KindGroup {
+			sub := a.Value.Group()
+			if len(sub) == 0 {
+				continue
+			}
+			if a.Key == "" {
+				addAttrs(m, groups, sub)
+			} else {
+				addAttrs(m, append(slices.Clip(groups), a.Key), sub)
+			}
+			continue
+		}
+
+		target := m
+		for _, g := range groups {
+			child, ok := target[g].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				target[g] = child
+			}
+			target = child
+		}
+		target[a.Key] = slogValue(a.Value)
+	}
+}
+
+func slogValue(v slog.Value) any {
+	switch v.Kind() {
+	case slog.KindTime:
+		return v.Time().UTC().Format(time.RFC3339Nano)
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return err.Error()
+		}
+		return v.Any()
+	default:
+		return v.Any()
+	}
+}
@@ -0,0 +1,72 @@
+package quickwit
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/slogtest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lastDoc flushes the handler and decodes the last document sent
+func lastDoc(t *testing.T, h *SlogHandler, c *fakeIngestClient) map[string]any {
+	require.NoError(t, h.Flush(context.Background()))
+
+	batches := c.Batches()
+	require.NotEmpty(t, batches)
+	lines := batches[len(batches)-1]
+
+	doc := map[string]any{}
+	require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &doc))
+	return doc
+}
+
+func TestSlogHandler(t *testing.T) {
+	c := &fakeIngestClient{}
+	h := NewSlogHandler(c, "logs", SlogHandlerConfig{AddSource: true, Ingester: IngesterConfig{FlushInterval: time.Hour}})
+	defer func() { _ = h.Close(context.Background()) }()
+
+	log := slog.New(h)
+	assert.False(t, h.Enabled(context.Background(), slog.LevelDebug))
+
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := slog.NewRecord(ts, slog.LevelWarn, "disk almost full", 0)
+	r.AddAttrs(slog.Int("used", 95))
+	require.NoError(t, log.With("host", "node-1").WithGroup("disk").Handler().Handle(context.Background(), r))
+
+	assert.Equal(t, map[string]any{
+		"timestamp": "2026-01-02T03:04:05Z",
+		"level":     "warn",
+		"message":   "disk almost full",
+		"fields":    map[string]any{"host": "node-1", "disk": map[string]any{"used": 95.0}},
+	}, lastDoc(t, h, c))
+
+	log.Info("with source")
+	source, ok := lastDoc(t, h, c)["caller"].(map[string]any)
+	require.True(t, ok)
+	assert.True(t, strings.HasSuffix(source["file"].(string), "slog_handler_test.go"))
+}
+
+func TestSlogHandlerConformance(t *testing.T) {
+	schema := LogSchema{TimestampField: slog.TimeKey, LevelField: slog.LevelKey, MessageField: slog.MessageKey, FlattenAttributes: true}
+
+	var h *SlogHandler
+	var c *fakeIngestClient
+	slogtest.Run(t, func(t *testing.T) slog.Handler {
+		if strings.HasSuffix(t.Name(), "/zero-time") {
+			t.Skip("a zero time is replaced by the current time, the index requires a timestamp")
+		}
+
+		c = &fakeIngestClient{}
+		h = NewSlogHandler(c, "logs", SlogHandlerConfig{Schema: schema, Ingester: IngesterConfig{FlushInterval: time.Hour}})
+		t.Cleanup(func() { _ = h.Close(context.Background()) })
+		return h
+	}, func(t *testing.T) map[string]any {
+		return lastDoc(t, h, c)
+	})
+}
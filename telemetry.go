@@ -0,0 +1,202 @@
+package quickwit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/CleverCloud/quickwit-go"
+
+type TelemetryConfig struct {
+	// Defaults to the global tracer provider
+	TracerProvider trace.TracerProvider
+	// Defaults to the global meter provider
+	MeterProvider metric.MeterProvider
+	// Defaults to W3C trace context
+	Propagator propagation.TextMapPropagator
+}
+
+type telemetry struct {
+	tracer       trace.Tracer
+	propagator   propagation.TextMapPropagator
+	duration     metric.Float64Histogram
+	requestSize  metric.Int64Histogram
+	responseSize metric.Int64Histogram
+}
+
+func newTelemetry(cfg TelemetryConfig) (*telemetry, error) {
+	if cfg.TracerProvider == nil {
+		cfg.TracerProvider = otel.GetTracerProvider()
+	}
+	if cfg.MeterProvider == nil {
+		cfg.MeterProvider = otel.GetMeterProvider()
+	}
+	if cfg.Propagator == nil {
+		cfg.Propagator = propagation.TraceContext{}
+	}
+
+	meter := cfg.MeterProvider.Meter(instrumentationName)
+
+	duration, err := meter.Float64Histogram(
+		"quickwit.client.request.duration",
+		metric.WithDescription("Duration of Quickwit API calls"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	requestSize, err := meter.Int64Histogram(
+		"quickwit.client.request.size",
+		metric.WithDescription("Size of Quickwit API request bodies"),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	responseSize, err := meter.Int64Histogram(
+		"quickwit.client.response.size",
+		metric.WithDescription("Size of Quickwit API response bodies"),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &telemetry{
+		tracer:       cfg.TracerProvider.Tracer(instrumentationName),
+		propagator:   cfg.Propagator,
+		duration:     duration,
+		requestSize:  requestSize,
+		responseSize: responseSize,
+	}, nil
+}
+
+// call tracks an API call from newRequest until its response is handled
+type call struct {
+	t     *telemetry
+	span  trace.Span
+	op    Operation
+	start time.Time
+
+	statusCode   int
+	requestSize  int64
+	responseSize int64
+	hits         *int
+}
+
+type callKey struct{}
+
+func (t *telemetry) start(ctx context.Context, op Operation) context.Context {
+	attrs := []attribute.KeyValue{
+		attribute.String("quickwit.operation", op.Name),
+		attribute.String("quickwit.operation.class", string(op.Class)),
+	}
+	if op.IndexID != "" {
+		attrs = append(attrs, attribute.String("quickwit.index_id", op.IndexID))
+	}
+
+	ctx, span := t.tracer.Start(ctx, "quickwit."+op.Name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+
+	return context.WithValue(ctx, callKey{}, &call{t: t, span: span, op: op, start: time.Now()})
+}
+
+// inject propagates the trace context of the call through the request headers
+func (t *telemetry) inject(req *http.Request) {
+	t.propagator.Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+}
+
+// callFromContext returns nil when telemetry is disabled, call methods accept a nil receiver
+func callFromContext(ctx context.Context) *call {
+	c, _ := ctx.Value(callKey{}).(*call)
+	return c
+}
+
+func (c *call) request(req *http.Request) {
+	if c == nil {
+		return
+	}
+
+	c.requestSize = req.ContentLength
+	c.span.SetAttributes(
+		attribute.String("http.request.method", req.Method),
+		attribute.String("server.address", req.URL.Host),
+	)
+}
+
+// response counts the bytes read from the body, error bodies included
+func (c *call) response(res *http.Response) {
+	if c == nil {
+		return
+	}
+
+	c.statusCode = res.StatusCode
+	res.Body = &countingReader{ReadCloser: res.Body, n: &c.responseSize}
+}
+
+func (c *call) setHits(n int) {
+	if c == nil {
+		return
+	}
+	c.hits = &n
+}
+
+func (c *call) end(err error) {
+	if c == nil {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("quickwit.operation", c.op.Name),
+		attribute.String("quickwit.operation.class", string(c.op.Class)),
+	}
+	if c.statusCode != 0 {
+		attrs = append(attrs, attribute.Int("http.response.status_code", c.statusCode))
+	}
+
+	ctx := trace.ContextWithSpan(context.Background(), c.span)
+	set := metric.WithAttributes(attrs...)
+	c.t.duration.Record(ctx, time.Since(c.start).Seconds(), set)
+	if c.requestSize > 0 {
+		c.t.requestSize.Record(ctx, c.requestSize, set)
+	}
+	c.t.responseSize.Record(ctx, c.responseSize, set)
+
+	c.span.SetAttributes(attrs...)
+	c.span.SetAttributes(
+		attribute.Int64("http.request.body.size", max(c.requestSize, 0)),
+		attribute.Int64("http.response.body.size", c.responseSize),
+	)
+	if c.hits != nil {
+		c.span.SetAttributes(attribute.Int("quickwit.hits", *c.hits))
+	}
+	if err != nil {
+		c.span.RecordError(err)
+		c.span.SetStatus(codes.Error, err.Error())
+	}
+	c.span.End()
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,96 @@
+package quickwit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTelemetry(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	metrics := sdkmetric.NewManualReader()
+
+	const notFound = "{\"message\": \"index `logs` not found\"}"
+	var traceparents []string
+	doer := doerFunc(func(req *http.Request) (*http.Response, error) {
+		traceparents = append(traceparents, req.Header.Get("traceparent"))
+		if req.URL.Path == "/api/v1/indexes/logs" {
+			return jsonResponse(http.StatusNotFound, notFound), nil
+		}
+		return jsonResponse(http.StatusOK, `[]`), nil
+	})
+
+	c := New(WithDoer(doer), WithLogger(NewNopLogger()), WithTelemetry(TelemetryConfig{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)),
+		MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(metrics)),
+	}))
+
+	_, err := c.ListIndexes(context.Background())
+	require.NoError(t, err)
+	_, err = c.GetIndex(context.Background(), "logs")
+	require.ErrorIs(t, err, ErrIndexNotFound)
+
+	t.Run("Spans", func(t *testing.T) {
+		ended := spans.Ended()
+		require.Len(t, ended, 2)
+
+		list, get := ended[0], ended[1]
+		assert.Equal(t, "quickwit."+OpListIndexes, list.Name())
+		assert.Equal(t, trace.SpanKindClient, list.SpanKind())
+		assert.Equal(t, codes.Unset, list.Status().Code)
+		assert.Contains(t, list.Attributes(), attribute.Int64("http.response.body.size", 2))
+
+		assert.Equal(t, "quickwit."+OpGetIndex, get.Name())
+		assert.Equal(t, codes.Error, get.Status().Code)
+		assert.Contains(t, get.Attributes(), attribute.String("quickwit.index_id", "logs"))
+		assert.Contains(t, get.Attributes(), attribute.Int("http.response.status_code", http.StatusNotFound))
+		assert.Contains(t, get.Attributes(), attribute.Int64("http.response.body.size", int64(len(notFound))))
+		require.Len(t, get.Events(), 1, "the error is recorded on the span")
+	})
+
+	t.Run("Propagation", func(t *testing.T) {
+		ended := spans.Ended()
+		require.Len(t, traceparents, 2)
+		for i, span := range ended {
+			sc := span.SpanContext()
+			assert.Equal(t, "00-"+sc.TraceID().String()+"-"+sc.SpanID().String()+"-01", traceparents[i])
+		}
+	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, metrics.Collect(context.Background(), &rm))
+		require.Len(t, rm.ScopeMetrics, 1)
+		assert.Equal(t, instrumentationName, rm.ScopeMetrics[0].Scope.Name)
+
+		histograms := map[string]metricdata.Histogram[int64]{}
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			if h, ok := m.Data.(metricdata.Histogram[int64]); ok {
+				histograms[m.Name] = h
+			}
+			if m.Name == "quickwit.client.request.duration" {
+				h, ok := m.Data.(metricdata.Histogram[float64])
+				require.True(t, ok)
+				assert.Len(t, h.DataPoints, 2)
+			}
+		}
+
+		responseSizes := map[string]int64{}
+		for _, dp := range histograms["quickwit.client.response.size"].DataPoints {
+			op, _ := dp.Attributes.Value("quickwit.operation")
+			responseSizes[op.AsString()] = dp.Sum
+		}
+		assert.Equal(t, map[string]int64{OpListIndexes: 2, OpGetIndex: int64(len(notFound))}, responseSizes)
+		assert.Empty(t, histograms["quickwit.client.request.size"].DataPoints, "bodyless requests are not recorded")
+	})
+}
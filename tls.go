@@ -0,0 +1,159 @@
+package quickwit
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+type TLSConfig struct {
+	// PEM bundle of the certificate authorities trusted in addition to the system ones
+	CAFile string
+	CAPEM  []byte
+	// Client certificate and key for mTLS, both files are read again when they change
+	CertFile string
+	KeyFile  string
+	// Overrides the name checked against the server certificate
+	ServerName string
+	// Minimum TLS version, defaults to TLS 1.2
+	MinVersion uint16
+	// Do not verify the server certificate, for tests only
+	InsecureSkipVerify bool
+}
+
+// Validate loads the CA bundle and the client certificate, New only logs these errors and every request then fails
+func (cfg TLSConfig) Validate() error {
+	_, err := cfg.build(nil)
+	return err
+}
+
+// build returns the tls.Config described by the options, merged onto a copy of base when it is not nil
+func (cfg TLSConfig) build(base *tls.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if base != nil {
+		tlsConfig = base.Clone()
+	}
+
+	if cfg.ServerName != "" {
+		tlsConfig.ServerName = cfg.ServerName
+	}
+	if cfg.MinVersion != 0 {
+		tlsConfig.MinVersion = cfg.MinVersion
+	}
+	if tlsConfig.MinVersion == 0 {
+		tlsConfig.MinVersion = tls.VersionTLS12
+	}
+	if cfg.InsecureSkipVerify {
+		tlsConfig.InsecureSkipVerify = true //nolint:gosec // opt-in
+	}
+
+	if cfg.CAFile != "" || len(cfg.CAPEM) > 0 {
+		var pool *x509.CertPool
+		if tlsConfig.RootCAs != nil {
+			pool = tlsConfig.RootCAs.Clone()
+		} else if system, err := x509.SystemCertPool(); err == nil {
+			pool = system
+		} else {
+			pool = x509.NewCertPool()
+		}
+
+		if cfg.CAFile != "" {
+			pem, err := os.ReadFile(cfg.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read CA bundle: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificate found in CA bundle %s", cfg.CAFile)
+			}
+		}
+		if len(cfg.CAPEM) > 0 && !pool.AppendCertsFromPEM(cfg.CAPEM) {
+			return nil, errors.New("no certificate found in CA PEM")
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("both the client certificate and key files must be set")
+	}
+	if cfg.CertFile != "" {
+		reloader := &certReloader{certFile: cfg.CertFile, keyFile: cfg.KeyFile}
+		if _, err := reloader.certificate(); err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return reloader.certificate()
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// certReloader loads the client certificate again whenever its files change
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+func (r *certReloader) certificate() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	certInfo, certErr := os.Stat(r.certFile)
+	keyInfo, keyErr := os.Stat(r.keyFile)
+	if err := errors.Join(certErr, keyErr); err != nil {
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("cannot stat client certificate: %w", err)
+	}
+
+	if r.cert != nil && certInfo.ModTime().Equal(r.certModTime) && keyInfo.ModTime().Equal(r.keyModTime) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		// files may be caught in the middle of a rotation, keep the previous pair meanwhile
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("cannot load client certificate: %w", err)
+	}
+
+	r.cert, r.certModTime, r.keyModTime = &cert, certInfo.ModTime(), keyInfo.ModTime()
+
+	return r.cert, nil
+}
+
+// tlsTransport returns a copy of the given transport using the TLS options.
+// The TLS options are merged onto the TLSClientConfig of the transport, which is left untouched.
+func tlsTransport(base http.RoundTripper, cfg TLSConfig) (http.RoundTripper, error) {
+	var t *http.Transport
+	switch b := base.(type) {
+	case nil:
+		t = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		t = b.Clone()
+	default:
+		return nil, errors.New("TLS options require the HTTP client transport to be an *http.Transport")
+	}
+
+	tlsConfig, err := cfg.build(t.TLSClientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+	}
+	t.TLSClientConfig = tlsConfig
+
+	return t, nil
+}
@@ -0,0 +1,206 @@
+package quickwit
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert returns a certificate signed by ca, or a self-signed CA when ca is nil
+func newTestCert(t *testing.T, cn string, ca *testCert, hosts ...string) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	parent, signer := tmpl, key
+	if ca == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		parent, signer = ca.cert, ca.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// write saves the certificate and key, with a modification time in the future so that rotations are detected
+func (c *testCert) write(t *testing.T, certFile, keyFile string, modTime time.Time) {
+	t.Helper()
+	for path, b := range map[string][]byte{certFile: c.certPEM, keyFile: c.keyPEM} {
+		require.NoError(t, os.WriteFile(path, b, 0o600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+}
+
+func TestTLS(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	serverCert := newTestCert(t, "quickwit.internal", ca, "quickwit.internal")
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Client", r.TLS.PeerCertificates[0].Subject.CommonName)
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.cert.Raw}, PrivateKey: serverCert.key}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	newTestCert(t, "client-1", ca).write(t, certFile, keyFile, time.Now())
+
+	// the server name comes from the caller transport, which must be left untouched
+	callerTLS := &tls.Config{ServerName: "quickwit.internal"}
+	hc := &http.Client{Transport: &http.Transport{TLSClientConfig: callerTLS, DisableKeepAlives: true}}
+
+	clientCN := func(t *testing.T, c Client) string {
+		res, err := c.(*client).doer.Do(mustRequest(t, srv.URL+"/api/v1/indexes"))
+		require.NoError(t, err)
+		defer func() { _ = res.Body.Close() }()
+		return res.Header.Get("X-Client")
+	}
+
+	c := New(WithEndpoint(srv.URL), WithHttpClient(hc), WithLogger(NewNopLogger()), WithTLS(TLSConfig{
+		CAPEM:    ca.certPEM,
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	}))
+
+	t.Run("mTLS", func(t *testing.T) {
+		_, err := c.ListIndexes(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "client-1", clientCN(t, c))
+
+		assert.Nil(t, callerTLS.RootCAs)
+		assert.Nil(t, callerTLS.GetClientCertificate)
+	})
+
+	t.Run("Hot Reload", func(t *testing.T) {
+		newTestCert(t, "client-2", ca).write(t, certFile, keyFile, time.Now().Add(time.Minute))
+		assert.Equal(t, "client-2", clientCN(t, c))
+
+		// a half-written rotation keeps the previous pair
+		require.NoError(t, os.WriteFile(keyFile, []byte("garbage"), 0o600))
+		assert.Equal(t, "client-2", clientCN(t, c))
+	})
+
+	t.Run("Untrusted Server", func(t *testing.T) {
+		c := New(WithEndpoint(srv.URL), WithHttpClient(hc), WithLogger(NewNopLogger()), WithTLS(TLSConfig{CAPEM: newTestCert(t, "other", nil).certPEM}))
+		_, err := c.ListIndexes(context.Background())
+		assert.ErrorContains(t, err, "certificate")
+	})
+}
+
+func TestTLSValidate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	newTestCert(t, "client", ca).write(t, certFile, keyFile, time.Now())
+	otherKey := filepath.Join(dir, "other-key.pem")
+	require.NoError(t, os.WriteFile(otherKey, newTestCert(t, "other", ca).keyPEM, 0o600))
+
+	for _, tt := range []struct {
+		name string
+		cfg  TLSConfig
+		err  string
+	}{
+		{name: "valid", cfg: TLSConfig{CAPEM: ca.certPEM, CertFile: certFile, KeyFile: keyFile}},
+		{name: "missing CA file", cfg: TLSConfig{CAFile: filepath.Join(dir, "missing.pem")}, err: "cannot read CA bundle"},
+		{name: "invalid CA", cfg: TLSConfig{CAPEM: []byte("not a certificate")}, err: "no certificate found"},
+		{name: "key without certificate", cfg: TLSConfig{KeyFile: keyFile}, err: "must be set"},
+		{name: "mismatched key", cfg: TLSConfig{CertFile: certFile, KeyFile: otherKey}, err: "cannot load client certificate"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	t.Run("Client", func(t *testing.T) {
+		c := New(WithLogger(NewNopLogger()), WithTLS(TLSConfig{CAPEM: []byte("not a certificate")}))
+		_, err := c.ListIndexes(context.Background())
+		assert.ErrorContains(t, err, "invalid TLS configuration")
+	})
+
+	t.Run("Profile", func(t *testing.T) {
+		t.Setenv(EnvCAFile, filepath.Join(dir, "missing.pem"))
+		_, err := NewFromEnv()
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "cannot read CA bundle")
+	})
+}
+
+func mustRequest(t *testing.T, url string) *http.Request {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	return req
+}
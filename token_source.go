@@ -0,0 +1,243 @@
+package quickwit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource provides the bearer token sent with each request
+type TokenSource interface {
+	// Token returns a valid token, it is called for every request and should cache
+	Token(ctx context.Context) (string, error)
+	// Invalidate drops a token rejected by Quickwit, the next Token call must fetch a fresh one
+	Invalidate(token string)
+}
+
+type staticTokenSource string
+
+// StaticTokenSource always returns the same token
+func StaticTokenSource(token string) TokenSource {
+	return staticTokenSource(token)
+}
+
+func (s staticTokenSource) Token(context.Context) (string, error) {
+	return string(s), nil
+}
+
+func (s staticTokenSource) Invalidate(string) {}
+
+// FileTokenSource reads the token from a file, which is read again whenever it changes.
+// It suits tokens mounted by secret managers and rotated in place.
+type FileTokenSource struct {
+	path string
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	size    int64
+}
+
+func NewFileTokenSource(path string) *FileTokenSource {
+	return &FileTokenSource{path: path}
+}
+
+func (s *FileTokenSource) Token(context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return "", fmt.Errorf("cannot stat token file: %w", err)
+	}
+
+	if s.token != "" && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return s.token, nil
+	}
+
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read token file: %w", err)
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", s.path)
+	}
+
+	s.token, s.modTime, s.size = token, info.ModTime(), info.Size()
+
+	return s.token, nil
+}
+
+func (s *FileTokenSource) Invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+type ClientCredentialsConfig struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// Additional form parameters sent to the token endpoint (audience...)
+	EndpointParams url.Values
+	// Send the client credentials in the form instead of a basic auth header
+	AuthInParams bool
+	// Tokens are refreshed this long before they expire, defaults to 30s
+	ExpiryDelta time.Duration
+	// Defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// ClientCredentialsTokenSource fetches tokens with the OAuth2 client credentials flow and caches them until they expire
+type ClientCredentialsTokenSource struct {
+	cfg ClientCredentialsConfig
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func NewClientCredentialsTokenSource(cfg ClientCredentialsConfig) *ClientCredentialsTokenSource {
+	if cfg.ExpiryDelta <= 0 {
+		cfg.ExpiryDelta = 30 * time.Second
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	return &ClientCredentialsTokenSource{cfg: cfg}
+}
+
+func (s *ClientCredentialsTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && (s.expiry.IsZero() || time.Now().Add(s.cfg.ExpiryDelta).Before(s.expiry)) {
+		return s.token, nil
+	}
+
+	token, expiresIn, err := s.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	s.token = token
+	s.expiry = time.Time{}
+	if expiresIn > 0 {
+		s.expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+
+	return s.token, nil
+}
+
+func (s *ClientCredentialsTokenSource) Invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (s *ClientCredentialsTokenSource) fetch(ctx context.Context) (string, int64, error) {
+	form := url.Values{}
+	for k, v := range s.cfg.EndpointParams {
+		form[k] = v
+	}
+	form.Set("grant_type", "client_credentials")
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+	if s.cfg.AuthInParams {
+		form.Set("client_id", s.cfg.ClientID)
+		form.Set("client_secret", s.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !s.cfg.AuthInParams {
+		req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+	}
+
+	res, err := s.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("cannot fetch token: %w", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", 0, fmt.Errorf("cannot read token response: %w", err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return "", 0, fmt.Errorf("cannot fetch token: %d - %s", res.StatusCode, string(body))
+	}
+
+	t := tokenResponse{}
+	if err := json.Unmarshal(body, &t); err != nil {
+		return "", 0, fmt.Errorf("cannot decode token response: %w", err)
+	}
+	if t.AccessToken == "" {
+		return "", 0, errors.New("token response has no access_token")
+	}
+
+	return t.AccessToken, t.ExpiresIn, nil
+}
+
+// tokenInterceptor sets the bearer token, on a 401 response the token is refreshed and the request sent once more
+func tokenInterceptor(ts TokenSource) Interceptor {
+	return func(ctx context.Context, req *http.Request, next Next) (*http.Response, error) {
+		token, err := ts.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		res, err := next(ctx, req)
+		if err != nil || res.StatusCode != http.StatusUnauthorized || !canRewind(req) {
+			return res, err
+		}
+
+		ts.Invalidate(token)
+		fresh, err := ts.Token(ctx)
+		if err != nil || fresh == token {
+			return res, nil
+		}
+
+		retry, err := rewindRequest(req)
+		if err != nil {
+			return res, nil
+		}
+		_, _ = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+
+		retry.Header.Set("Authorization", "Bearer "+fresh)
+
+		return next(ctx, retry)
+	}
+}
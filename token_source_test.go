@@ -0,0 +1,151 @@
+package quickwit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileTokenSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("first\n"), 0o600))
+
+	ts := NewFileTokenSource(path)
+	token, err := ts.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "first", token)
+
+	// rotated in place, the size and modification time change
+	require.NoError(t, os.WriteFile(path, []byte("rotated-token\n"), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	token, err = ts.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "rotated-token", token)
+
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+	_, err = ts.Token(context.Background())
+	assert.ErrorContains(t, err, "is empty")
+
+	require.NoError(t, os.Remove(path))
+	_, err = ts.Token(context.Background())
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// tokenServer issues token-1, token-2... with the client credentials flow
+func tokenServer(t *testing.T, expiresIn int) (*httptest.Server, *atomic.Int32) {
+	issued := &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "client", user)
+		assert.Equal(t, "s3cr3t", password)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "search ingest", r.PostForm.Get("scope"))
+		assert.Equal(t, "quickwit", r.PostForm.Get("audience"))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": %d}`, issued.Add(1), expiresIn)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, issued
+}
+
+func TestClientCredentialsTokenSource(t *testing.T) {
+	newSource := func(url string) *ClientCredentialsTokenSource {
+		return NewClientCredentialsTokenSource(ClientCredentialsConfig{
+			TokenURL:       url,
+			ClientID:       "client",
+			ClientSecret:   "s3cr3t",
+			Scopes:         []string{"search", "ingest"},
+			EndpointParams: map[string][]string{"audience": {"quickwit"}},
+		})
+	}
+
+	t.Run("Caching", func(t *testing.T) {
+		srv, issued := tokenServer(t, 3600)
+		ts := newSource(srv.URL)
+
+		for range 3 {
+			token, err := ts.Token(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, "token-1", token)
+		}
+		assert.Equal(t, int32(1), issued.Load())
+
+		ts.Invalidate("token-1")
+		token, err := ts.Token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "token-2", token)
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		// expires within the default 30s expiry delta, every call fetches a new token
+		srv, issued := tokenServer(t, 10)
+		ts := newSource(srv.URL)
+
+		for range 2 {
+			_, err := ts.Token(context.Background())
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int32(2), issued.Load())
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"error": "invalid_client"}`, http.StatusUnauthorized)
+		}))
+		defer srv.Close()
+
+		_, err := newSource(srv.URL).Token(context.Background())
+		assert.ErrorContains(t, err, "invalid_client")
+	})
+}
+
+func TestTokenRefresh(t *testing.T) {
+	tokens, issued := tokenServer(t, 3600)
+
+	valid := "token-2"
+	seen := []string{}
+	quickwit := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		seen = append(seen, auth)
+		if auth != "Bearer "+valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer quickwit.Close()
+
+	ts := NewClientCredentialsTokenSource(ClientCredentialsConfig{
+		TokenURL:       tokens.URL,
+		ClientID:       "client",
+		ClientSecret:   "s3cr3t",
+		Scopes:         []string{"search", "ingest"},
+		EndpointParams: map[string][]string{"audience": {"quickwit"}},
+	})
+	c := New(WithEndpoint(quickwit.URL), WithTokenSource(ts), WithLogger(NewNopLogger()))
+
+	// token-1 is rejected, the request is sent once more with token-2
+	_, err := c.ListIndexes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Bearer token-1", "Bearer token-2"}, seen)
+	assert.Equal(t, int32(2), issued.Load())
+
+	// the fresh token is rejected too, there is a single retry
+	seen = nil
+	valid = "none"
+	_, err = c.ListIndexes(context.Background())
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Equal(t, []string{"Bearer token-2", "Bearer token-3"}, seen)
+}
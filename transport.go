@@ -0,0 +1,283 @@
+package quickwit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Doer sends HTTP requests, *http.Client implements it
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Middleware wraps the transport used by the client.
+// Middlewares must not modify the request they receive, they clone it instead.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is an adapter to use ordinary functions as http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Chain wraps rt with the middlewares, the first middleware being the outermost
+func Chain(rt http.RoundTripper, middlewares ...Middleware) http.RoundTripper {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		rt = middlewares[i](rt)
+	}
+	return rt
+}
+
+// Operation describes the API call a request belongs to, middlewares get it with OperationFromContext
+type Operation struct {
+	Name    string
+	IndexID string
+	Class   OperationClass
+}
+
+// OperationClass groups operations with a similar load profile
+type OperationClass string
+
+const (
+	ClassSearch OperationClass = "search"
+	ClassIngest OperationClass = "ingest"
+	ClassAdmin  OperationClass = "admin"
+)
+
+func operationClass(name string) OperationClass {
+	switch name {
+	case OpSearch:
+		return ClassSearch
+	case OpIngest:
+		return ClassIngest
+	default:
+		return ClassAdmin
+	}
+}
+
+const (
+	OpSearch        = "search"
+	OpListIndexes   = "list_indexes"
+	OpGetIndex      = "get_index"
+	OpCreateIndex   = "create_index"
+	OpUpdateIndex   = "update_index"
+	OpDeleteIndex   = "delete_index"
+	OpClearIndex    = "clear_index"
+	OpDescribeIndex = "describe_index"
+	OpListSplits    = "list_splits"
+	OpIngest        = "ingest"
+	OpCreateSource  = "create_source"
+	OpDeleteSource  = "delete_source"
+	OpGetElastic    = "get_elastic"
+	OpGetCluster    = "get_cluster"
+	OpLivez         = "livez"
+	OpReadyz        = "readyz"
+	OpVersion       = "version"
+	OpMetrics       = "metrics"
+	OpSetLogLevel   = "set_log_level"
+	OpCPUProfile    = "cpu_profile"
+	OpFlamegraph    = "flamegraph"
+	OpHeapProfile   = "heap_profile"
+)
+
+type operationKey struct{}
+
+func withOperation(ctx context.Context, op Operation) context.Context {
+	return context.WithValue(ctx, operationKey{}, op)
+}
+
+func OperationFromContext(ctx context.Context) (Operation, bool) {
+	op, ok := ctx.Value(operationKey{}).(Operation)
+	return op, ok
+}
+
+// RequestMetrics is reported by MetricsMiddleware for each request sent
+type RequestMetrics struct {
+	Operation  Operation
+	Method     string
+	Host       string
+	Path       string
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// MetricsMiddleware calls observe once per request with its outcome and duration
+func MetricsMiddleware(observe func(RequestMetrics)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			res, err := next.RoundTrip(req)
+
+			op, _ := OperationFromContext(req.Context())
+			m := RequestMetrics{
+				Operation: op,
+				Method:    req.Method,
+				Host:      req.URL.Host,
+				Path:      req.URL.Path,
+				Duration:  time.Since(start),
+				Err:       err,
+			}
+			if res != nil {
+				m.StatusCode = res.StatusCode
+			}
+			observe(m)
+
+			return res, err
+		})
+	}
+}
+
+// LoggingMiddleware logs each request sent with its request ID, status code and latency at debug level
+func LoggingMiddleware(log Logger) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			res, err := next.RoundTrip(req)
+			latency := time.Since(start)
+
+			reqID := req.Header.Get(RequestIDHeader)
+			if err != nil {
+				log.Debug("quickwit request error", "request_id", reqID, "method", req.Method, "url", req.URL.String(), "latency", latency, "error", err)
+				return nil, err
+			}
+
+			log.Debug("quickwit request", "request_id", reqID, "method", req.Method, "url", req.URL.String(), "status", res.StatusCode, "latency", latency)
+
+			return res, nil
+		})
+	}
+}
+
+// Next passes the request to the rest of the chain, and eventually to Quickwit
+type Next func(ctx context.Context, req *http.Request) (*http.Response, error)
+
+// Interceptor wraps a request: it can alter the request, fail before sending it, or inspect the response.
+// The request is a copy owned by the interceptor chain, its headers can be modified in place.
+type Interceptor func(ctx context.Context, req *http.Request, next Next) (*http.Response, error)
+
+// InterceptorError is returned when an interceptor fails on its own, for example when it cannot get a token.
+// The request may not have been sent: it is not retried and does not count against the node pool or the circuit breaker.
+type InterceptorError struct {
+	Err error
+}
+
+func (e *InterceptorError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InterceptorError) Unwrap() error {
+	return e.Err
+}
+
+func isInterceptorError(err error) bool {
+	var ie *InterceptorError
+	return errors.As(err, &ie)
+}
+
+// interceptorsMiddleware runs the interceptors around the rest of the transport.
+// Errors which do not come from the transport are wrapped in an *InterceptorError.
+func interceptorsMiddleware(interceptors []Interceptor) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			var transportErr error
+			send := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+				if ctx != req.Context() {
+					req = req.WithContext(ctx)
+				}
+				res, err := next.RoundTrip(req)
+				transportErr = err
+				return res, err
+			}
+
+			for i := len(interceptors) - 1; i >= 0; i-- {
+				interceptor, inner := interceptors[i], send
+				send = func(ctx context.Context, req *http.Request) (*http.Response, error) {
+					if ctx != req.Context() {
+						req = req.WithContext(ctx)
+					}
+					return interceptor(ctx, req, inner)
+				}
+			}
+
+			req = req.Clone(req.Context())
+			res, err := send(req.Context(), req)
+			if err != nil && (transportErr == nil || !errors.Is(err, transportErr)) {
+				return res, &InterceptorError{Err: err}
+			}
+			return res, err
+		})
+	}
+}
+
+// doerTransport lets middlewares wrap a Doer provided with WithDoer
+type doerTransport struct {
+	doer Doer
+}
+
+func (t doerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.doer.Do(req)
+}
+
+// buildDoer wraps the configured transport with the middlewares, outermost first:
+// user middlewares, retry, hedging, rate limiter, node pool, circuit breaker, interceptors, SigV4 signing, logging.
+// The node pool picks the host before the circuit breaker, so that circuits are tracked per node.
+// Signing comes last so that it covers every header set before the request is sent.
+func (c *client) buildDoer() Doer {
+	middlewares := append([]Middleware{}, c.middlewares...)
+	if c.retry != nil {
+		middlewares = append(middlewares, RetryMiddleware(*c.retry))
+	}
+	if c.hedger != nil {
+		middlewares = append(middlewares, c.hedger.Middleware())
+	}
+	if c.limiter != nil {
+		middlewares = append(middlewares, c.limiter.Middleware())
+	}
+	if c.pool != nil {
+		middlewares = append(middlewares, c.pool.Middleware())
+	}
+	if c.breaker != nil {
+		middlewares = append(middlewares, c.breaker.Middleware())
+	}
+	if len(c.interceptors) > 0 {
+		middlewares = append(middlewares, interceptorsMiddleware(c.interceptors))
+	}
+	if c.signer != nil {
+		middlewares = append(middlewares, c.signer.Middleware())
+	}
+	middlewares = append(middlewares, LoggingMiddleware(c.log))
+
+	if c.customDoer != nil {
+		if c.tls != nil {
+			c.log.Error("TLS options are ignored when a custom Doer is set")
+		}
+		return &http.Client{
+			Transport: Chain(doerTransport{doer: c.customDoer}, middlewares...),
+			// redirects are left to the wrapped Doer
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+	}
+
+	// shallow copy so the timeout, cookie jar and redirect policy of the provided client are kept
+	hc := *c.httpClient
+	base := hc.Transport
+	if c.tls != nil {
+		t, err := tlsTransport(base, *c.tls)
+		if err != nil {
+			c.log.Error("requests will fail", "error", err)
+			base = RoundTripperFunc(func(*http.Request) (*http.Response, error) { return nil, err })
+		} else {
+			base = t
+		}
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	hc.Transport = Chain(base, middlewares...)
+
+	return &hc
+}
@@ -0,0 +1,124 @@
+package quickwit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func jsonResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestTransportOptions(t *testing.T) {
+	t.Run("HTTP Client", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`[]`))
+		}))
+		defer srv.Close()
+
+		calls := atomic.Int32{}
+		hc := &http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			return http.DefaultTransport.RoundTrip(req)
+		})}
+
+		c := New(WithEndpoint(srv.URL), WithHttpClient(hc), WithLogger(NewNopLogger()))
+		_, err := c.ListIndexes(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("Doer", func(t *testing.T) {
+		var paths []string
+		doer := doerFunc(func(req *http.Request) (*http.Response, error) {
+			paths = append(paths, req.Method+" "+req.URL.Path)
+			return jsonResponse(http.StatusOK, `[]`), nil
+		})
+
+		// nothing listens on the endpoint, every call must go through the Doer
+		c := New(WithEndpoint("http://127.0.0.1:1"), WithDoer(doer), WithLogger(NewNopLogger()))
+		_, err := c.ListIndexes(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, c.DeleteIndex(context.Background(), "logs"))
+
+		assert.Equal(t, []string{"GET /api/v1/indexes", "DELETE /api/v1/indexes/logs"}, paths)
+	})
+}
+
+func TestInterceptorErrors(t *testing.T) {
+	calls := atomic.Int32{}
+	var transportErr error
+	doer := doerFunc(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		if transportErr != nil {
+			return nil, transportErr
+		}
+		return jsonResponse(http.StatusOK, `[]`), nil
+	})
+
+	tokenErr := errors.New("token endpoint unavailable")
+	intercepted := 0
+	failing := func(ctx context.Context, req *http.Request, next Next) (*http.Response, error) {
+		intercepted++
+		if transportErr == nil {
+			return nil, tokenErr
+		}
+		return next(ctx, req)
+	}
+
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1})
+	pool, err := NewNodePool(NodePoolConfig{Seeds: []string{"http://node-1:7280"}, DiscoveryInterval: -1, EjectAfter: 1, EjectFor: time.Minute})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	c := New(
+		WithDoer(doer),
+		WithLogger(NewNopLogger()),
+		WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+		WithNodePool(pool),
+		WithCircuitBreaker(breaker),
+		WithInterceptor(failing),
+	)
+
+	for range 3 {
+		_, err := c.ListIndexes(context.Background())
+		var ie *InterceptorError
+		require.ErrorAs(t, err, &ie)
+		assert.ErrorIs(t, err, tokenErr)
+	}
+	assert.Zero(t, calls.Load())
+	assert.Equal(t, 3, intercepted, "interceptor errors are not retried")
+	assert.Equal(t, CircuitClosed, breaker.State("node-1:7280"))
+	for _, n := range pool.Nodes() {
+		assert.True(t, n.EjectedUntil.IsZero(), "interceptor errors must not eject nodes")
+		assert.Zero(t, n.InFlight)
+	}
+
+	// transport errors passed through by the interceptor are not wrapped, the first one opens the circuit
+	transportErr = errors.New("connection reset")
+	_, err = c.ListIndexes(context.Background())
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.False(t, isInterceptorError(err))
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, CircuitOpen, breaker.State("node-1:7280"))
+}
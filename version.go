@@ -0,0 +1,161 @@
+package quickwit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var ErrUnsupported = errors.New("quickwit: unsupported by this Quickwit version")
+
+// DefaultIndexConfigVersion is set by CreateIndex and CreateSource when the cluster version cannot be fetched
+const DefaultIndexConfigVersion = "0.9"
+
+// UnsupportedError is returned when a feature is not available on the cluster version, it matches ErrUnsupported
+type UnsupportedError struct {
+	Feature string
+	Version string
+	// First version supporting the feature
+	MinVersion string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("quickwit: %s requires Quickwit %s or later, cluster runs %s", e.Feature, e.MinVersion, e.Version)
+}
+
+func (e *UnsupportedError) Is(target error) bool {
+	return target == ErrUnsupported
+}
+
+type VersionInfo struct {
+	Build   BuildInfo   `json:"build"`
+	Runtime RuntimeInfo `json:"runtime"`
+}
+
+type BuildInfo struct {
+	BuildDate       string   `json:"build_date"`
+	BuildProfile    string   `json:"build_profile"`
+	BuildTarget     string   `json:"build_target"`
+	CargoPkgVersion string   `json:"cargo_pkg_version"`
+	CommitDate      string   `json:"commit_date"`
+	CommitHash      string   `json:"commit_hash"`
+	CommitShortHash string   `json:"commit_short_hash"`
+	CommitTags      []string `json:"commit_tags"`
+	Version         string   `json:"version"`
+}
+
+type RuntimeInfo struct {
+	NumCPUsLogical        int `json:"num_cpus_logical"`
+	NumCPUsPhysical       int `json:"num_cpus_physical"`
+	NumThreadsBlocking    int `json:"num_threads_blocking"`
+	NumThreadsNonBlocking int `json:"num_threads_non_blocking"`
+}
+
+// Capabilities tells which request shapes and features the cluster supports, derived from its version
+type Capabilities struct {
+	Version string
+	Major   int
+	Minor   int
+	Patch   int
+	// Edge builds are assumed to support every feature
+	Edge bool
+	// Version to set in IndexConfig and SourceConfig
+	IndexConfigVersion string
+	// PUT /api/v1/indexes/{id}
+	UpdateIndex bool
+}
+
+// AtLeast tells whether the cluster runs the given version or a later one
+func (c Capabilities) AtLeast(major, minor int) bool {
+	return c.Edge || c.Major > major || (c.Major == major && c.Minor >= minor)
+}
+
+// require returns an *UnsupportedError when the cluster is older than the given version
+func (c Capabilities) require(feature string, major, minor int) error {
+	if c.AtLeast(major, minor) {
+		return nil
+	}
+	return &UnsupportedError{Feature: feature, Version: c.Version, MinVersion: fmt.Sprintf("%d.%d", major, minor)}
+}
+
+// NewCapabilities derives the capabilities of a Quickwit version ("0.8.2", "0.9.0-rc1", "edge")
+func NewCapabilities(version string) (Capabilities, error) {
+	c := Capabilities{Version: version}
+
+	if strings.Contains(version, "edge") || strings.Contains(version, "nightly") {
+		c.Edge = true
+	} else {
+		core, _, _ := strings.Cut(strings.TrimPrefix(version, "v"), "-")
+		parts := strings.Split(core, ".")
+		if len(parts) < 2 {
+			return c, fmt.Errorf("cannot parse Quickwit version %q", version)
+		}
+
+		numbers := make([]int, 3)
+		for i, part := range parts[:min(len(parts), 3)] {
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return c, fmt.Errorf("cannot parse Quickwit version %q", version)
+			}
+			numbers[i] = n
+		}
+		c.Major, c.Minor, c.Patch = numbers[0], numbers[1], numbers[2]
+	}
+
+	switch {
+	case c.AtLeast(0, 9):
+		c.IndexConfigVersion = "0.9"
+	case c.AtLeast(0, 8):
+		c.IndexConfigVersion = "0.8"
+	default:
+		c.IndexConfigVersion = "0.7"
+	}
+	c.UpdateIndex = c.AtLeast(0, 9)
+
+	return c, nil
+}
+
+// Version returns the build information of the node answering
+func (c *client) Version(ctx context.Context) (*VersionInfo, error) {
+	req, err := c.newRequest(ctx, Operation{Name: OpVersion}, http.MethodGet, "/api/v1/version", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return Request[VersionInfo](c.doer, c.log, req)
+}
+
+// Capabilities returns the capabilities of the cluster, fetched once and cached by the client.
+// Concurrent first calls may all fetch the version, the lock is not held during the request.
+func (c *client) Capabilities(ctx context.Context) (*Capabilities, error) {
+	c.capsMu.Lock()
+	caps := c.caps
+	c.capsMu.Unlock()
+	if caps != nil {
+		return caps, nil
+	}
+
+	v, err := c.Version(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get Quickwit version: %w", err)
+	}
+
+	version := v.Build.Version
+	if version == "" || version == "unknown" {
+		version = v.Build.CargoPkgVersion
+	}
+	fetched, err := NewCapabilities(version)
+	if err != nil {
+		return nil, err
+	}
+
+	c.capsMu.Lock()
+	defer c.capsMu.Unlock()
+	if c.caps == nil {
+		c.caps = &fetched
+	}
+	return c.caps, nil
+}
@@ -0,0 +1,123 @@
+package quickwit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapabilities(t *testing.T) {
+	for _, tt := range []struct {
+		version            string
+		indexConfigVersion string
+		updateIndex        bool
+	}{
+		{"0.7.1", "0.7", false},
+		{"0.8.2", "0.8", false},
+		{"0.9.0-rc1", "0.9", true},
+		{"v0.9.1", "0.9", true},
+		{"edge", "0.9", true},
+	} {
+		t.Run(tt.version, func(t *testing.T) {
+			caps, err := NewCapabilities(tt.version)
+			require.NoError(t, err)
+			assert.Equal(t, tt.indexConfigVersion, caps.IndexConfigVersion)
+			assert.Equal(t, tt.updateIndex, caps.UpdateIndex)
+		})
+	}
+
+	_, err := NewCapabilities("unknown")
+	assert.Error(t, err)
+
+	caps, err := NewCapabilities("0.8.2")
+	require.NoError(t, err)
+	err = caps.require("UpdateIndex", 0, 9)
+	assert.ErrorIs(t, err, ErrUnsupported)
+	assert.EqualError(t, err, "quickwit: UpdateIndex requires Quickwit 0.9 or later, cluster runs 0.8.2")
+}
+
+func TestConfigVersion(t *testing.T) {
+	versionStatus := http.StatusInternalServerError
+	var created IndexConfig
+	var source SourceConfig
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/version":
+			w.WriteHeader(versionStatus)
+			_, _ = fmt.Fprint(w, `{"build": {"version": "0.8.2"}}`)
+		case "/api/v1/indexes":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+			_, _ = fmt.Fprint(w, `{}`)
+		case "/api/v1/indexes/logs/sources":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&source))
+			_, _ = fmt.Fprint(w, `{}`)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(WithEndpoint(srv.URL), WithLogger(NewNopLogger()))
+
+	// the version endpoint fails, the default version is used and nothing is cached
+	_, err := c.CreateIndex(context.Background(), IndexConfig{ID: "logs"})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultIndexConfigVersion, created.Version)
+	_, err = c.CreateSource(context.Background(), "logs", NewPulsarSourceConfig("pulsar", "pulsar://pulsar:6650", "", "logs"))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultIndexConfigVersion, source.Version)
+
+	versionStatus = http.StatusOK
+	_, err = c.CreateIndex(context.Background(), IndexConfig{ID: "logs"})
+	require.NoError(t, err)
+	assert.Equal(t, "0.8", created.Version)
+	_, err = c.CreateSource(context.Background(), "logs", NewPulsarSourceConfig("pulsar", "pulsar://pulsar:6650", "", "logs"))
+	require.NoError(t, err)
+	assert.Equal(t, "0.8", source.Version)
+
+	// explicit versions are kept
+	_, err = c.CreateSource(context.Background(), "logs", SourceConfig{Version: "0.7", ID: "kafka", Type: "kafka"})
+	require.NoError(t, err)
+	assert.Equal(t, "0.7", source.Version)
+}
+
+func TestCapabilitiesConcurrency(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"build": {"version": "0.9.1"}}`)
+	}))
+	defer srv.Close()
+
+	c := New(WithEndpoint(srv.URL), WithLogger(NewNopLogger()))
+
+	slow := make(chan error, 1)
+	go func() {
+		_, err := c.Capabilities(context.Background())
+		slow <- err
+	}()
+
+	// a slow fetch does not block the callers with a shorter deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.Capabilities(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	close(release)
+	require.NoError(t, <-slow)
+
+	caps, err := c.Capabilities(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "0.9.1", caps.Version)
+}